@@ -0,0 +1,102 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Value logged instead of redacted params.
+const Redacted = "REDACTED"
+
+// AccessLogger logs every request dispatched to the routes in its scope
+// through log/slog.
+type AccessLogger struct {
+	// Logger to write to. If nil, slog.Default() is used.
+	Logger *slog.Logger
+	// Fraction of requests logged, between 0 and 1. Zero logs every
+	// request. Server errors are always logged.
+	SampleRate float64
+	// Names of the params whose values must not be logged, e.g.
+	// access_token. They are redacted from the raw path too.
+	Redact []string
+	// Header holding the request ID when the tree doesn't assign them
+	// with WithRequestID. If empty, X-Request-ID is used.
+	RequestIDHeader string
+}
+
+// Logs every request dispatched to the routes registered inside the
+// closure. Wrap the whole tree definition to log all of it.
+func (m *Medeina) AccessLog(logger *AccessLogger, handle Handle) {
+	m.Use(logger.Middleware, handle)
+}
+
+// Wraps a route handle, logging its requests once served.
+func (al *AccessLogger) Middleware(route *Route, handle httprouter.Handle) httprouter.Handle {
+	redact := make(map[string]bool, len(al.Redact))
+	for _, name := range al.Redact {
+		redact[name] = true
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		rw := newResponseWriter(w)
+		start := time.Now()
+		handle(rw, r, ps)
+		elapsed := time.Since(start)
+		status := rw.Status()
+		if status < http.StatusInternalServerError && al.SampleRate > 0 && rand.Float64() >= al.SampleRate {
+			return
+		}
+		params := Params(ps)
+		path := r.URL.Path
+		attrs := make([]interface{}, 0, len(ps))
+		if len(redact) > 0 {
+			params = make(Params, len(ps))
+			copy(params, ps)
+			for i, p := range params {
+				if redact[p.Key] {
+					params[i].Value = Redacted
+				}
+			}
+			path = route.expand(params)
+		}
+		for _, p := range params {
+			attrs = append(attrs, slog.String(p.Key, p.Value))
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		al.logger().LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route.Path),
+			slog.String("path", path),
+			slog.Group("params", attrs...),
+			slog.Int("status", status),
+			slog.Int64("bytes", rw.written),
+			slog.Duration("duration", elapsed),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("client_ip", ClientIP(r)),
+			slog.String("request_id", al.requestID(r)),
+		)
+	}
+}
+
+func (al *AccessLogger) logger() *slog.Logger {
+	if al.Logger == nil {
+		return slog.Default()
+	}
+	return al.Logger
+}
+
+func (al *AccessLogger) requestID(r *http.Request) string {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	if al.RequestIDHeader == "" {
+		return r.Header.Get("X-Request-ID")
+	}
+	return r.Header.Get(al.RequestIDHeader)
+}
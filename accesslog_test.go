@@ -0,0 +1,85 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := &AccessLogger{
+		Logger: slog.New(slog.NewJSONHandler(buffer, nil)),
+		Redact: []string{"access_token"},
+	}
+	mr := NewMedeina()
+	mr.On("applications/:client_id/tokens", func() {
+		mr.AccessLog(logger, func() {
+			mr.Is(":access_token", testHandler, GET)
+		})
+		mr.Is("", testHandler, DELETE)
+	})
+	r, _ := http.NewRequest("GET", "/applications/medeina/tokens/s3cr3t", nil)
+	r.Header.Set("X-Request-ID", "42")
+	r.RemoteAddr = "192.0.2.1:1234"
+	mr.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest("DELETE", "/applications/medeina/tokens", nil)
+	mr.ServeHTTP(httptest.NewRecorder(), r)
+
+	if strings.Contains(buffer.String(), "s3cr3t") {
+		t.Errorf("Expected access token redacted, found %s", buffer.String())
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the scoped route logged, found %q", lines)
+	}
+	var entry struct {
+		Method     string
+		Route      string
+		Path       string
+		Params     map[string]string
+		Status     int
+		Bytes      int
+		RemoteAddr string `json:"remote_addr"`
+		RequestID  string `json:"request_id"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Route != "/applications/:client_id/tokens/:access_token" ||
+		entry.Path != "/applications/medeina/tokens/"+Redacted ||
+		entry.Params["client_id"] != "medeina" ||
+		entry.Params["access_token"] != Redacted ||
+		entry.Status != http.StatusOK || entry.Bytes == 0 ||
+		entry.RemoteAddr != "192.0.2.1:1234" || entry.RequestID != "42" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := &AccessLogger{
+		Logger:     slog.New(slog.NewJSONHandler(buffer, nil)),
+		SampleRate: 0.000001,
+	}
+	mr := NewMedeina()
+	mr.AccessLog(logger, func() {
+		mr.Is("ok", testHandler, GET)
+		mr.Is("ko", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, GET)
+	})
+	for _, path := range []string{"/ok", "/ok", "/ko"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		mr.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if lines := strings.Split(strings.TrimSpace(buffer.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"status":502`) {
+		t.Errorf("Expected only the server error logged, found %q", lines)
+	}
+}
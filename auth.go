@@ -0,0 +1,216 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+var (
+	// Returned by authenticators when the request carries no credentials
+	// for their scheme.
+	ErrNoCredentials = errors.New("medeina: no credentials")
+	// Returned by authenticators when the credentials aren't valid.
+	ErrInvalidCredentials = errors.New("medeina: invalid credentials")
+)
+
+// Principal is the authenticated identity behind a request.
+type Principal struct {
+	Name       string
+	Roles      []string
+	Attributes map[string]string
+}
+
+// Tells if the principal has the given role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the principal behind a request. Implement it to
+// support custom schemes.
+type Authenticator interface {
+	// Returns the principal or an error, usually ErrNoCredentials or
+	// ErrInvalidCredentials.
+	Authenticate(r *http.Request) (*Principal, error)
+	// Returns the WWW-Authenticate challenge sent along 401 responses, if any.
+	Challenge() string
+}
+
+// Policy decides if a principal is allowed to reach a route.
+type Policy interface {
+	Allow(principal *Principal, r *http.Request, ps Params) bool
+}
+
+// Allows using a simple function as Policy.
+type PolicyFunc func(principal *Principal, r *http.Request, ps Params) bool
+
+func (f PolicyFunc) Allow(principal *Principal, r *http.Request, ps Params) bool {
+	return f(principal, r, ps)
+}
+
+// Returns a policy which requires all the given roles.
+func RequireRoles(roles ...string) Policy {
+	return PolicyFunc(func(principal *Principal, _ *http.Request, _ Params) bool {
+		for _, role := range roles {
+			if !principal.HasRole(role) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+type principalKey struct{}
+
+// Returns the principal authenticated for the request, if any.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Returns a copy of the context holding the principal.
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Requires a principal for every route registered inside the closure.
+// Requests failing authentication get a 401 response.
+func (m *Medeina) Authenticate(authenticator Authenticator, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.Protected = true
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil || principal == nil {
+				if challenge := authenticator.Challenge(); challenge != "" {
+					w.Header().Set("WWW-Authenticate", challenge)
+				}
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			handle(w, r.WithContext(ContextWithPrincipal(r.Context(), principal)), ps)
+		}
+	}, handle)
+}
+
+// Checks a policy for every route registered inside the closure. Requests
+// without principal get a 401 response, and the ones denied a 403.
+// Authorize scopes nest: every policy must allow the request.
+func (m *Medeina) Authorize(policy Policy, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.Authorized = true
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			principal := PrincipalFromContext(r.Context())
+			if principal == nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !policy.Allow(principal, r, Params(ps)) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			handle(w, r, ps)
+		}
+	}, handle)
+}
+
+type basicAuth struct {
+	realm    string
+	validate func(user, password string) (*Principal, error)
+}
+
+// Returns an authenticator for the Basic scheme.
+func BasicAuth(realm string, validate func(user, password string) (*Principal, error)) Authenticator {
+	return &basicAuth{realm: realm, validate: validate}
+}
+
+func (a *basicAuth) Authenticate(r *http.Request) (*Principal, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return a.validate(user, password)
+}
+
+func (a *basicAuth) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q", a.realm)
+}
+
+type bearerAuth struct {
+	realm    string
+	validate func(token string) (*Principal, error)
+}
+
+// Returns an authenticator for the Bearer scheme.
+func BearerAuth(realm string, validate func(token string) (*Principal, error)) Authenticator {
+	return &bearerAuth{realm: realm, validate: validate}
+}
+
+func (a *bearerAuth) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.validate(strings.TrimSpace(token))
+}
+
+func (a *bearerAuth) Challenge() string {
+	return fmt.Sprintf("Bearer realm=%q", a.realm)
+}
+
+type apiKeyAuth struct {
+	header   string
+	validate func(key string) (*Principal, error)
+}
+
+// Returns an authenticator reading an API key from the given header.
+func APIKeyAuth(header string, validate func(key string) (*Principal, error)) Authenticator {
+	return &apiKeyAuth{header: header, validate: validate}
+}
+
+func (a *apiKeyAuth) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(a.header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.validate(key)
+}
+
+func (a *apiKeyAuth) Challenge() string {
+	return ""
+}
+
+type anyAuth []Authenticator
+
+// Returns an authenticator trying each of the given ones in order, until
+// one finds credentials.
+func AnyAuth(authenticators ...Authenticator) Authenticator {
+	return anyAuth(authenticators)
+}
+
+func (a anyAuth) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(r)
+		if err != ErrNoCredentials {
+			return principal, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+func (a anyAuth) Challenge() string {
+	for _, authenticator := range a {
+		if challenge := authenticator.Challenge(); challenge != "" {
+			return challenge
+		}
+	}
+	return ""
+}
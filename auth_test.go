@@ -0,0 +1,94 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loadAuthMedeina() *Medeina {
+	users := map[string]*Principal{
+		"dario": {Name: "dario", Roles: []string{"admin"}},
+		"julia": {Name: "julia"},
+	}
+	validate := func(token string) (*Principal, error) {
+		if principal, ok := users[token]; ok {
+			return principal, nil
+		}
+		return nil, ErrInvalidCredentials
+	}
+	authenticator := AnyAuth(
+		BearerAuth("medeina", validate),
+		APIKeyAuth("X-API-Key", validate),
+		BasicAuth("medeina", func(user, password string) (*Principal, error) {
+			if password != "secret" {
+				return nil, ErrInvalidCredentials
+			}
+			return validate(user)
+		}),
+	)
+	whoami := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		fmt.Fprint(w, PrincipalFromContext(r.Context()).Name)
+	}
+	mr := NewMedeina()
+	mr.Is("emojis", testHandler, GET)
+	mr.Authenticate(authenticator, func() {
+		mr.On("user", func() {
+			mr.Is("", whoami, GET)
+			mr.Authorize(RequireRoles("admin"), func() {
+				mr.Is("keys", whoami, GET)
+			})
+		})
+	})
+	return mr
+}
+
+func TestAuth(t *testing.T) {
+	mr := loadAuthMedeina()
+	for _, test := range []struct {
+		path   string
+		header string
+		value  string
+		status int
+		body   string
+	}{
+		{"/emojis", "", "", http.StatusOK, ""},
+		{"/user", "", "", http.StatusUnauthorized, ""},
+		{"/user", "Authorization", "Bearer nobody", http.StatusUnauthorized, ""},
+		{"/user", "Authorization", "Bearer julia", http.StatusOK, "julia"},
+		{"/user", "X-API-Key", "dario", http.StatusOK, "dario"},
+		{"/user", "Authorization", "Basic ZGFyaW86c2VjcmV0", http.StatusOK, "dario"},
+		{"/user/keys", "Authorization", "Bearer julia", http.StatusForbidden, ""},
+		{"/user/keys", "Authorization", "Bearer dario", http.StatusOK, "dario"},
+	} {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("Expected %d for %s with %s %s, found %d", test.status, test.path, test.header, test.value, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("Expected principal %s for %s, found %s", test.body, test.path, w.Body.String())
+		}
+		if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+			t.Errorf("Expected Bearer challenge for %s, found %q", test.path, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	flags := make(map[string]string)
+	for _, route := range loadAuthMedeina().Routes() {
+		flags[route.Path] = strings.Join(route.Flags(), ",")
+	}
+	if flags["/emojis"] != "" || flags["/user"] != "protected" || flags["/user/keys"] != "protected,authorized" {
+		t.Errorf("Unexpected route flags %v", flags)
+	}
+}
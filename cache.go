@@ -0,0 +1,382 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	linked "container/list"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response kept by a Cache.
+type CachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Stored  time.Time
+	Expires time.Time
+	// Request headers the response varies on.
+	Vary []string
+}
+
+// CacheStore keeps cached responses. Keys of the same route and params
+// share a prefix, so they can be purged together.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, response *CachedResponse)
+	DeletePrefix(prefix string)
+}
+
+// Cache stores the responses of GET routes, keyed by route pattern, params,
+// selected query keys and the request headers named by Vary. The same Cache
+// can be used in several scopes, sharing its store.
+type Cache struct {
+	// Time to live of responses without max-age. Zero doesn't cache them.
+	TTL time.Duration
+	// Time to live by route pattern, overriding TTL.
+	TTLs map[string]time.Duration
+	// Query params taken into account. Others are ignored.
+	QueryKeys []string
+	// Responses larger than this aren't cached. Defaults to 1 MB.
+	MaxBodySize int
+	// Defaults to an in-memory LRU store of 1024 responses.
+	Store CacheStore
+
+	once     sync.Once
+	mutex    sync.Mutex
+	patterns []string
+}
+
+func (c *Cache) init() {
+	c.once.Do(func() {
+		if c.Store == nil {
+			c.Store = NewLRUCacheStore(1024)
+		}
+		if c.MaxBodySize == 0 {
+			c.MaxBodySize = 1 << 20
+		}
+	})
+}
+
+// Builds the key prefix shared by the responses of a route and params.
+func cachePrefix(pattern string, ps Params) string {
+	var b strings.Builder
+	b.WriteString(pattern)
+	b.WriteByte(0)
+	for _, p := range ps {
+		b.WriteString(url.QueryEscape(p.Key))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.Value))
+		b.WriteByte('&')
+	}
+	b.WriteByte(0)
+	return b.String()
+}
+
+// Builds the key of a request, without its Vary part.
+func (c *Cache) key(route *Route, r *http.Request, ps Params) string {
+	var b strings.Builder
+	b.WriteString(cachePrefix(route.Path, ps))
+	query := r.URL.Query()
+	for _, key := range c.QueryKeys {
+		for _, value := range query[key] {
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+			b.WriteByte('&')
+		}
+	}
+	b.WriteByte(0)
+	return b.String()
+}
+
+// Appends the values of the headers a response varies on to a key.
+func varyKey(key string, r *http.Request, vary []string) string {
+	for _, name := range vary {
+		key += strings.ToLower(name) + ":" + strings.Join(r.Header.Values(name), ",") + "\n"
+	}
+	return key
+}
+
+// Purges the cached responses of a route pattern. If params are given, only
+// the responses for those params are purged.
+func (c *Cache) Purge(pattern string, ps Params) {
+	c.init()
+	if len(ps) == 0 {
+		c.Store.DeletePrefix(pattern + "\x00")
+		return
+	}
+	c.Store.DeletePrefix(cachePrefix(pattern, ps))
+}
+
+// Returns the time to live of a response, or zero if it can't be stored.
+func (c *Cache) ttl(pattern string, header http.Header) time.Duration {
+	ttl := c.TTL
+	if routeTTL, ok := c.TTLs[pattern]; ok {
+		ttl = routeTTL
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "private" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "s-maxage="):
+			if seconds, err := strconv.Atoi(directive[len("s-maxage="):]); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(directive[len("max-age="):]); err == nil {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if header.Get("Vary") == "*" || header.Get("Set-Cookie") != "" {
+		return 0
+	}
+	return ttl
+}
+
+// Tells if the request asks to bypass the cache, or carries credentials
+// making its response specific to a client: an Authorization or Cookie
+// header, or a principal set by Authenticate.
+func bypassCache(r *http.Request) bool {
+	cc := strings.ToLower(r.Header.Get("Cache-Control"))
+	if strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store") {
+		return true
+	}
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" || PrincipalFromContext(r.Context()) != nil
+}
+
+// Caches the responses of GET routes registered inside the closure.
+// Routes inside Authenticate or Authorize scopes aren't cached, as their
+// responses depend on the principal. Requests with credentials, cookies or
+// an authenticated principal, or asking for no-cache, skip the cache, and
+// responses are stored only if they're 200 OK and their Cache-Control
+// allows it.
+// Successful POST, PUT, PATCH and DELETE requests to routes inside the
+// closure purge the cached responses of the GET routes they're under, e.g.
+// a PUT to /repos/:owner/:repo/topics purges /repos/:owner/:repo for the
+// same owner and repo.
+func (m *Medeina) Cache(cache *Cache, handle Handle) {
+	cache.init()
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		if route.Method == "GET" && (route.Protected || route.Authorized) {
+			return handle
+		}
+		route.Cached = true
+		switch route.Method {
+		case "GET":
+			cache.mutex.Lock()
+			cache.patterns = append(cache.patterns, route.Path)
+			cache.mutex.Unlock()
+			return cache.serve(route, handle)
+		case "POST", "PUT", "PATCH", "DELETE":
+			return cache.invalidate(route, handle)
+		}
+		return handle
+	}, handle)
+}
+
+// Serves responses from the cache, storing the missing ones.
+func (c *Cache) serve(route *Route, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if bypassCache(r) {
+			handle(w, r, ps)
+			return
+		}
+		key := c.key(route, r, Params(ps))
+		if index, ok := c.Store.Get(key); ok {
+			if cached, ok := c.Store.Get(varyKey(key, r, index.Vary)); ok && time.Now().Before(cached.Expires) {
+				header := w.Header()
+				for name, values := range cached.Header {
+					header[name] = values
+				}
+				header.Set("Age", strconv.Itoa(int(time.Since(cached.Stored).Seconds())))
+				header.Set("X-Cache", "HIT")
+				w.WriteHeader(cached.Status)
+				w.Write(cached.Body)
+				return
+			}
+		}
+		w.Header().Set("X-Cache", "MISS")
+		tw := &teeWriter{ResponseWriter: w, limit: c.MaxBodySize}
+		handle(tw, r, ps)
+		if tw.status != http.StatusOK || tw.overflow {
+			return
+		}
+		ttl := c.ttl(route.Path, tw.header)
+		if ttl <= 0 {
+			return
+		}
+		var vary []string
+		for _, value := range tw.header.Values("Vary") {
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					vary = append(vary, http.CanonicalHeaderKey(name))
+				}
+			}
+		}
+		sort.Strings(vary)
+		now := time.Now()
+		response := &CachedResponse{
+			Status:  tw.status,
+			Header:  tw.header,
+			Body:    tw.body.Bytes(),
+			Stored:  now,
+			Expires: now.Add(ttl),
+			Vary:    vary,
+		}
+		response.Header.Del("X-Cache")
+		if len(vary) > 0 {
+			// The entry without Vary part tells which headers the
+			// route varies on.
+			c.Store.Set(key, &CachedResponse{Vary: vary, Expires: response.Expires})
+		}
+		c.Store.Set(varyKey(key, r, vary), response)
+	}
+}
+
+// Purges the GET routes an unsafe route is under once it succeeds.
+func (c *Cache) invalidate(route *Route, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		rw := newResponseWriter(w)
+		handle(rw, r, ps)
+		if status := rw.Status(); status < 200 || status >= 300 {
+			return
+		}
+		c.mutex.Lock()
+		patterns := append([]string(nil), c.patterns...)
+		c.mutex.Unlock()
+		for _, pattern := range patterns {
+			if pattern != route.Path && !strings.HasPrefix(route.Path, strings.TrimSuffix(pattern, "/")+"/") {
+				continue
+			}
+			// Params of the pattern are a prefix of the route ones.
+			n := strings.Count(pattern, "/:") + strings.Count(pattern, "/*")
+			if n > len(ps) {
+				continue
+			}
+			c.Purge(pattern, Params(ps[:n]))
+		}
+	}
+}
+
+// Copies a response while it is being sent.
+type teeWriter struct {
+	http.ResponseWriter
+	limit    int
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (tw *teeWriter) WriteHeader(code int) {
+	if tw.status == 0 && code >= 200 {
+		tw.status = code
+		tw.header = tw.ResponseWriter.Header().Clone()
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *teeWriter) Write(b []byte) (int, error) {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if !tw.overflow {
+		if tw.body.Len()+len(b) > tw.limit {
+			tw.overflow = true
+			tw.body.Reset()
+		} else {
+			tw.body.Write(b)
+		}
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *teeWriter) Flush() {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *teeWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// LRUCacheStore is an in-memory CacheStore evicting the least recently used
+// responses.
+type LRUCacheStore struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*linked.Element
+	order    *linked.List
+}
+
+type lruEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// Returns a new LRU store holding up to capacity responses.
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	return &LRUCacheStore{
+		capacity: capacity,
+		entries:  make(map[string]*linked.Element),
+		order:    linked.New(),
+	}
+}
+
+func (s *LRUCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*lruEntry).response, true
+}
+
+func (s *LRUCacheStore) Set(key string, response *CachedResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*lruEntry).response = response
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, response: response})
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (s *LRUCacheStore) DeletePrefix(prefix string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, element := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.order.Remove(element)
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Returns the number of responses stored.
+func (s *LRUCacheStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.order.Len()
+}
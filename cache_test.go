@@ -0,0 +1,139 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func loadCacheMedeina(cache *Cache, hits map[string]int) *Medeina {
+	count := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		key := r.URL.Path + "?" + r.URL.RawQuery + " " + r.Header.Get("Accept-Language")
+		hits[key]++
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, "%s %d", key, hits[key])
+	}
+	mr := NewMedeina()
+	mr.Cache(cache, func() {
+		mr.On("repos/:owner/:repo", func() {
+			mr.Is("", count, GET)
+			mr.Is("topics", testHandlerParams, PUT)
+			mr.Is("private", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				w.Header().Set("Cache-Control", "private")
+				count(w, r, ps)
+			}, GET)
+		})
+	})
+	return mr
+}
+
+func cacheRequest(mr *Medeina, method, path string, headers ...string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestCache(t *testing.T) {
+	hits := make(map[string]int)
+	cache := &Cache{TTL: time.Minute, QueryKeys: []string{"page"}}
+	mr := loadCacheMedeina(cache, hits)
+	first := cacheRequest(mr, "GET", "/repos/imdario/medeina?page=1&utm=x")
+	second := cacheRequest(mr, "GET", "/repos/imdario/medeina?page=1&utm=y")
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected cached response, found %s %q", second.Header().Get("X-Cache"), second.Body.String())
+	}
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina?page=2"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("Expected selected query keys in the cache key")
+	}
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina?page=1", "Accept-Language", "lt"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("Expected Vary headers in the cache key")
+	}
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina?page=1", "Cache-Control", "no-cache"); w.Header().Get("X-Cache") != "" {
+		t.Error("Expected no-cache requests to skip the cache")
+	}
+	cacheRequest(mr, "GET", "/repos/imdario/medeina/private")
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina/private"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("Not expected private responses cached")
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	hits := make(map[string]int)
+	cache := &Cache{TTLs: map[string]time.Duration{"/repos/:owner/:repo": time.Minute}}
+	mr := loadCacheMedeina(cache, hits)
+	cacheRequest(mr, "GET", "/repos/imdario/medeina")
+	cacheRequest(mr, "GET", "/repos/imdario/mergo")
+	cacheRequest(mr, "PUT", "/repos/imdario/medeina/topics")
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("Expected PUT under the route to purge it")
+	}
+	if w := cacheRequest(mr, "GET", "/repos/imdario/mergo"); w.Header().Get("X-Cache") != "HIT" {
+		t.Error("Not expected other params purged")
+	}
+	cache.Purge("/repos/:owner/:repo", nil)
+	if w := cacheRequest(mr, "GET", "/repos/imdario/mergo"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("Expected whole route purged")
+	}
+}
+
+func TestCachePrincipals(t *testing.T) {
+	mr := NewMedeina()
+	mr.Authenticate(APIKeyAuth("X-API-Key", func(key string) (*Principal, error) {
+		return &Principal{Name: key}, nil
+	}), func() {
+		mr.Cache(&Cache{TTL: time.Minute}, func() {
+			mr.Is("me", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				fmt.Fprint(w, PrincipalFromContext(r.Context()).Name)
+			}, GET)
+		})
+	})
+	cacheRequest(mr, "GET", "/me", "X-API-Key", "alice")
+	if w := cacheRequest(mr, "GET", "/me", "X-API-Key", "bob"); w.Body.String() != "bob" || w.Header().Get("X-Cache") != "" {
+		t.Errorf("Expected authenticated requests to skip the cache, found %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	hits := make(map[string]int)
+	mr = loadCacheMedeina(&Cache{TTL: time.Minute}, hits)
+	cacheRequest(mr, "GET", "/repos/imdario/medeina", "Cookie", "session=alice")
+	if w := cacheRequest(mr, "GET", "/repos/imdario/medeina", "Cookie", "session=bob"); w.Header().Get("X-Cache") != "" {
+		t.Error("Expected requests with cookies to skip the cache")
+	}
+}
+
+func TestCacheOutsideAuthenticate(t *testing.T) {
+	mr := NewMedeina()
+	mr.Cache(&Cache{TTL: time.Minute}, func() {
+		mr.Authenticate(APIKeyAuth("X-API-Key", func(key string) (*Principal, error) {
+			return &Principal{Name: key}, nil
+		}), func() {
+			mr.Is("me", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				fmt.Fprint(w, PrincipalFromContext(r.Context()).Name)
+			}, GET)
+		})
+	})
+	cacheRequest(mr, "GET", "/me", "X-API-Key", "alice")
+	if w := cacheRequest(mr, "GET", "/me"); w.Body.String() == "alice" || w.Header().Get("X-Cache") != "" {
+		t.Errorf("Not expected protected routes cached, found %s %q", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if routes := mr.Routes(); routes[0].Cached {
+		t.Error("Not expected protected routes marked as cached")
+	}
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	store := NewLRUCacheStore(2)
+	store.Set("a", &CachedResponse{})
+	store.Set("b", &CachedResponse{})
+	store.Get("a")
+	store.Set("c", &CachedResponse{})
+	if _, ok := store.Get("b"); ok || store.Len() != 2 {
+		t.Error("Expected least recently used entry evicted")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+/*
+Medeina-gen generates route constants, typed URL builders and param structs
+from the routes of a Medeina tree. It is meant for go generate:
+
+	//go:generate medeina-gen -config routes.yaml -o routes_gen.go
+
+Routes are read from a declarative file, as loaded by medeinaconfig, or
+with -routes from the JSON written by medeinagen.WriteRoutes. The package
+defaults to the one running go generate.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/imdario/medeina/medeinagen"
+	"os"
+)
+
+func main() {
+	config := flag.String("config", "", "declarative YAML or JSON file with the routes")
+	routesFile := flag.String("routes", "", "JSON file with the routes written by medeinagen.WriteRoutes")
+	output := flag.String("o", "", "output file, standard output if empty")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated code")
+	flag.Parse()
+	if (*config == "") == (*routesFile == "") {
+		fail(fmt.Errorf("either -config or -routes is required"))
+	}
+	if *pkg == "" {
+		fail(fmt.Errorf("-package is required outside go generate"))
+	}
+	routes, err := readRoutes(*config, *routesFile)
+	if err != nil {
+		fail(err)
+	}
+	code, err := medeinagen.Generate(*pkg, routes)
+	if err != nil {
+		fail(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(*output, code, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func readRoutes(config, routesFile string) ([]medeinagen.Route, error) {
+	if config != "" {
+		data, err := os.ReadFile(config)
+		if err != nil {
+			return nil, err
+		}
+		return medeinagen.FromConfig(data, config)
+	}
+	f, err := os.Open(routesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return medeinagen.ReadRoutes(f)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "medeina-gen:", err)
+	os.Exit(1)
+}
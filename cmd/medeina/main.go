@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+/*
+Medeina checks declarative route files, as loaded by medeinaconfig.
+
+	medeina lint [-json] file...
+
+It reports conflicting, shadowed and duplicate routes, params named
+differently at the same depth and empty On scopes, with their position in
+the files. It exits with status 1 if any error is found. Warnings alone
+don't fail.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/imdario/medeina"
+	"github.com/imdario/medeina/medeinaconfig"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: medeina lint [-json] file...")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "lint" {
+		usage()
+	}
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	flags.Usage = usage
+	flags.Parse(os.Args[2:])
+	if flags.NArg() == 0 {
+		usage()
+	}
+	diagnostics := []medeinaconfig.Diagnostic{}
+	for _, file := range flags.Args() {
+		diagnostics = append(diagnostics, lint(file)...)
+	}
+	failed := false
+	for _, d := range diagnostics {
+		if d.Severity == medeina.SeverityError {
+			failed = true
+		}
+		if !*asJSON {
+			fmt.Println(d)
+		}
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		encoder.Encode(diagnostics)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Lints a file, turning the problems of the document into diagnostics.
+func lint(file string) []medeinaconfig.Diagnostic {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return []medeinaconfig.Diagnostic{{
+			Diagnostic: medeina.Diagnostic{Severity: medeina.SeverityError, Check: "config", Message: err.Error()},
+			File:       file,
+		}}
+	}
+	diagnostics, err := medeinaconfig.Lint(data, file)
+	if errs, ok := err.(medeinaconfig.Errors); ok {
+		for _, e := range errs {
+			diagnostics = append(diagnostics, medeinaconfig.Diagnostic{
+				Diagnostic: medeina.Diagnostic{Severity: medeina.SeverityError, Check: "config", Message: e.Message},
+				File:       e.File,
+				Line:       e.Line,
+				Column:     e.Column,
+			})
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression configures response compression for a scope.
+type Compression struct {
+	// Compression level, as in compress/flate. Zero uses the default one.
+	Level int
+	// Responses smaller than this aren't compressed. Defaults to 1024
+	// bytes; negative compresses everything.
+	MinSize int
+	// Disables compression in a scope nested in a compressed one.
+	Disabled bool
+}
+
+// Media types which are already compressed, or not worth it.
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-bzip2", "application/x-7z-compressed", "application/zstd",
+	"application/octet-stream", "application/pdf",
+}
+
+// Compresses the responses of every route registered inside the closure
+// with gzip or deflate, as negotiated through Accept-Encoding. Small
+// responses, already compressed content types, partial content and
+// bodiless statuses are sent as they are. HEAD requests get the headers of
+// the matching GET, judging by the body or the Content-Length set by the
+// handler. Deeper scopes override the outer ones.
+func (m *Medeina) Compress(compression *Compression, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		if route.Compression != nil {
+			return handle
+		}
+		route.Compression = compression
+		if compression.Disabled {
+			return handle
+		}
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				handle(w, r, ps)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				compression:    compression,
+				encoding:       encoding,
+				head:           r.Method == "HEAD",
+			}
+			defer cw.Close()
+			handle(cw, r, ps)
+		}
+	}, handle)
+}
+
+// Picks gzip or deflate, in that order of preference, unless refused with
+// q=0.
+func negotiateEncoding(accept string) string {
+	qualities := encodingQualities(accept)
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		if q := encodingQuality(qualities, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// Parses the quality of each coding in an Accept-Encoding header.
+func encodingQualities(accept string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+	return qualities
+}
+
+// Returns the quality of a coding, falling back to the one of *, or zero
+// if it isn't accepted.
+func encodingQuality(qualities map[string]float64, coding string) float64 {
+	if q, ok := qualities[coding]; ok {
+		return q
+	}
+	return qualities["*"]
+}
+
+// Buffers the beginning of a response until it can decide whether to
+// compress it or not.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+	status      int
+	buffer      []byte
+	decided     bool
+	compressor  io.WriteCloser
+	// Tells if it answers a HEAD request.
+	head bool
+}
+
+func (cw *compressWriter) minSize() int {
+	if cw.compression.MinSize == 0 {
+		return 1024
+	}
+	return cw.compression.MinSize
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code < 200 {
+		// Informational responses go straight through.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+	header := cw.ResponseWriter.Header()
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent || header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, b...)
+		if len(cw.buffer) < cw.minSize() {
+			return len(b), nil
+		}
+		if err := cw.decide(cw.compressible()); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Tells if the response content type is worth compressing.
+func (cw *compressWriter) compressible() bool {
+	header := cw.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || cw.status == http.StatusPartialContent {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buffer)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, incompressible := range incompressibleTypes {
+		if strings.HasPrefix(mediaType, incompressible) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sends the headers, starting the compression if asked, and the buffered
+// bytes.
+func (cw *compressWriter) decide(compress bool) error {
+	if cw.decided {
+		return nil
+	}
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+	if compress {
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(cw.buffer))
+		}
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		// Strong validators don't hold for the compressed representation.
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		level := cw.compression.Level
+		if level == 0 {
+			level = flate.DefaultCompression
+		}
+		// HEAD responses get the headers of the compressed body, but not
+		// the body.
+		var body io.Writer = cw.ResponseWriter
+		if cw.head {
+			body = io.Discard
+		}
+		var err error
+		if cw.encoding == "gzip" {
+			cw.compressor, err = gzip.NewWriterLevel(body, level)
+		} else {
+			cw.compressor, err = flate.NewWriter(body, level)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buffer) > 0 {
+		buffer := cw.buffer
+		cw.buffer = nil
+		if cw.compressor != nil {
+			_, err := cw.compressor.Write(buffer)
+			return err
+		}
+		_, err := cw.ResponseWriter.Write(buffer)
+		return err
+	}
+	return nil
+}
+
+// Streams what was written so far. Undecided responses get compressed, as
+// their final size can't be known.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(cw.compressible())
+	}
+	if f, ok := cw.compressor.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok && !cw.decided {
+		cw.decided = true
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T doesn't support hijacking", cw.ResponseWriter)
+}
+
+// Finishes the response, sending small ones uncompressed.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.head && len(cw.buffer) == 0 {
+			// HEAD handlers may only tell the length of the body.
+			length, err := strconv.Atoi(cw.ResponseWriter.Header().Get("Content-Length"))
+			if cw.status == 0 && err != nil {
+				cw.decided = true
+				return nil
+			}
+			if cw.status == 0 {
+				cw.status = http.StatusOK
+			}
+			return cw.decide(err == nil && length >= cw.minSize() && cw.compressible())
+		}
+		if cw.status == 0 && len(cw.buffer) == 0 {
+			// Nothing was written: let net/http answer as usual.
+			cw.decided = true
+			return nil
+		}
+		cw.decide(false)
+	}
+	if cw.compressor != nil {
+		return cw.compressor.Close()
+	}
+	return nil
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var lorem = strings.Repeat("Medeina is a goddess willing to help you with your trees. ", 50)
+
+func loadCompressMedeina() *Medeina {
+	text := func(body string) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprint(w, body)
+		}
+	}
+	mr := NewMedeina()
+	mr.Compress(&Compression{}, func() {
+		mr.Is("large", text(lorem), GET, "HEAD")
+		mr.Is("small", text("tiny"), GET)
+		mr.Is("image", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, lorem)
+		}, GET)
+		mr.Is("partial", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(lorem)-1, 2*len(lorem)))
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, lorem)
+		}, GET)
+		mr.Is("length", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Length", "4096")
+		}, "HEAD")
+		mr.Is("cached", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.WriteHeader(http.StatusNotModified)
+		}, GET)
+		mr.Is("stream", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: 1\n\n")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, "data: 2\n\n")
+		}, GET)
+		mr.Compress(&Compression{Disabled: true}, func() {
+			mr.Is("raw", text(lorem), GET)
+		})
+	})
+	return mr
+}
+
+func compressRequest(mr *Medeina, method, path, accept string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	r.Header.Set("Accept-Encoding", accept)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	mr := loadCompressMedeina()
+	w := compressRequest(mr, "GET", "/large", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected gzip response, found %v", w.Header())
+	}
+	gr, _ := gzip.NewReader(w.Body)
+	if body, _ := io.ReadAll(gr); string(body) != lorem {
+		t.Errorf("Unexpected uncompressed body %q", body)
+	}
+	w = compressRequest(mr, "GET", "/large", "gzip;q=0, deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Expected deflate response, found %v", w.Header())
+	}
+	if body, _ := io.ReadAll(flate.NewReader(w.Body)); string(body) != lorem {
+		t.Errorf("Unexpected inflated body %q", body)
+	}
+	for _, test := range []struct {
+		method string
+		path   string
+		accept string
+		status int
+		body   string
+	}{
+		{"GET", "/large", "identity", http.StatusOK, lorem},
+		{"GET", "/small", "gzip", http.StatusOK, "tiny"},
+		{"GET", "/image", "gzip", http.StatusOK, lorem},
+		{"GET", "/cached", "gzip", http.StatusNotModified, ""},
+		{"GET", "/partial", "gzip", http.StatusPartialContent, lorem},
+		{"GET", "/raw", "gzip", http.StatusOK, lorem},
+	} {
+		w := compressRequest(mr, test.method, test.path, test.accept)
+		if w.Code != test.status || w.Header().Get("Content-Encoding") != "" || w.Body.String() != test.body {
+			t.Errorf("Expected %s %s sent as it is, found Code=%d %v", test.method, test.path, w.Code, w.Header())
+		}
+	}
+}
+
+func TestCompressHEAD(t *testing.T) {
+	mr := loadCompressMedeina()
+	get := compressRequest(mr, "GET", "/large", "gzip")
+	for _, path := range []string{"/large", "/length"} {
+		w := compressRequest(mr, "HEAD", path, "gzip")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != get.Header().Get("Vary") {
+			t.Errorf("Expected HEAD %s negotiated as GET, found Code=%d %v", path, w.Code, w.Header())
+		}
+		if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "" {
+			t.Errorf("Not expected a body for HEAD %s, found %d bytes %v", path, w.Body.Len(), w.Header())
+		}
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	w := compressRequest(loadCompressMedeina(), "GET", "/stream", "gzip")
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected flushed gzip stream, found %v", w.Header())
+	}
+	gr, _ := gzip.NewReader(w.Body)
+	if body, _ := io.ReadAll(gr); string(body) != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("Unexpected stream %q", body)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy configures Cross-Origin Resource Sharing for a scope.
+type CORSPolicy struct {
+	// Origins allowed to call the routes. They can be exact, like
+	// https://example.com, use a wildcard subdomain, like
+	// https://*.example.com, or be "*" to allow any origin.
+	AllowedOrigins []string
+	// Regular expressions matching allowed origins.
+	AllowedOriginPatterns []*regexp.Regexp
+	// Request headers allowed in preflight requests. "*" allows any
+	// requested header.
+	AllowedHeaders []string
+	// Response headers exposed to the browser.
+	ExposedHeaders []string
+	// Allows cookies and HTTP authentication. Origins are then always
+	// echoed, never answered with "*", so it can't be combined with the
+	// "*" origin.
+	AllowCredentials bool
+	// How long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// Tells if an origin is allowed by the policy.
+func (p *CORSPolicy) allowOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range p.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the headers common to preflight and actual requests. Returns false
+// if the origin isn't allowed.
+func (p *CORSPolicy) setOrigin(w http.ResponseWriter, origin string) bool {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	if origin == "" || !p.allowOrigin(origin) {
+		return false
+	}
+	if len(p.AllowedOrigins) == 1 && p.AllowedOrigins[0] == "*" && !p.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// Keeps track of the routes registered inside a CORS scope.
+type cors struct {
+	policy  *CORSPolicy
+	paths   []*Route
+	methods map[string][]string
+}
+
+// Answers actual cross-origin requests, unless an inner scope already
+// applies its own policy.
+func (c *cors) middleware(route *Route, handle httprouter.Handle) httprouter.Handle {
+	if route.CORS {
+		return handle
+	}
+	route.CORS = true
+	if _, ok := c.methods[route.Path]; !ok {
+		c.paths = append(c.paths, route)
+	}
+	c.methods[route.Path] = append(c.methods[route.Path], route.Method)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if c.policy.setOrigin(w, r.Header.Get("Origin")) && len(c.policy.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.policy.ExposedHeaders, ", "))
+		}
+		handle(w, r, ps)
+	}
+}
+
+// Answers preflight requests for a path.
+func (c *cors) preflight(path string) httprouter.Handle {
+	methods := append([]string{OPTIONS}, c.methods[path]...)
+	sort.Strings(methods)
+	allowed := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		header := w.Header()
+		header.Set("Allow", allowed)
+		method := r.Header.Get("Access-Control-Request-Method")
+		if method != "" && c.allowMethod(path, method) && c.policy.setOrigin(w, r.Header.Get("Origin")) {
+			header.Set("Access-Control-Allow-Methods", allowed)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				if len(c.policy.AllowedHeaders) == 1 && c.policy.AllowedHeaders[0] == "*" {
+					header.Set("Access-Control-Allow-Headers", requested)
+				} else if len(c.policy.AllowedHeaders) > 0 {
+					header.Set("Access-Control-Allow-Headers", strings.Join(c.policy.AllowedHeaders, ", "))
+				}
+			}
+			if c.policy.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.policy.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (c *cors) allowMethod(path, method string) bool {
+	for _, m := range c.methods[path] {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies a CORS policy to every route registered inside the closure.
+// Allowed methods are derived from the routes actually registered, and
+// preflight OPTIONS requests are answered for every path in the scope,
+// unless an OPTIONS route was registered by hand. Inner scopes override
+// outer ones.
+func (m *Medeina) CORS(policy *CORSPolicy, handle Handle) {
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" && policy.AllowCredentials {
+			panic(fmt.Errorf("you cannot allow credentials from any origin"))
+		}
+	}
+	c := &cors{
+		policy:  policy,
+		methods: make(map[string][]string),
+	}
+	m.Use(c.middleware, handle)
+	for _, route := range c.paths {
+		if m.hasRoute(OPTIONS, route.Path) {
+			continue
+		}
+		preflight := &Route{
+			Method:      OPTIONS,
+			Path:        route.Path,
+			Scope:       route.Scope,
+			MethodScope: route.MethodScope,
+			CORS:        true,
+		}
+		m.add(preflight, m.dispatch(preflight, c.preflight(route.Path)))
+	}
+}
+
+// Tells if a route was registered for the given method and path.
+func (m *Medeina) hasRoute(method, path string) bool {
+	for _, route := range m.routes {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
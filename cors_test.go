@@ -0,0 +1,128 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func loadCORSMedeina() *Medeina {
+	mr := NewMedeina()
+	mr.On("api/v1", func() {
+		mr.CORS(&CORSPolicy{
+			AllowedOrigins:        []string{"https://example.com", "https://*.medeina.dev"},
+			AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+			AllowedHeaders:        []string{"Content-Type", "Authorization"},
+			ExposedHeaders:        []string{"X-Total-Count"},
+			AllowCredentials:      true,
+			MaxAge:                10 * time.Minute,
+		}, func() {
+			mr.On("gists", func() {
+				mr.Is("", testHandler, GET, POST)
+				mr.Is(":id", testHandler, GET, DELETE)
+			})
+		})
+		mr.Is("emojis", testHandler, GET)
+	})
+	return mr
+}
+
+func corsRequest(mr *Medeina, method, path, origin string, headers ...string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	mr := loadCORSMedeina()
+	w := corsRequest(mr, "OPTIONS", "/api/v1/gists/1", "https://api.medeina.dev",
+		"Access-Control-Request-Method", "DELETE",
+		"Access-Control-Request-Headers", "authorization")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected preflight answered, found Code=%d", w.Code)
+	}
+	for header, value := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://api.medeina.dev",
+		"Access-Control-Allow-Methods":     "DELETE, GET, OPTIONS",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	} {
+		if w.Header().Get(header) != value {
+			t.Errorf("Expected %s %q, found %q", header, value, w.Header().Get(header))
+		}
+	}
+	w = corsRequest(mr, "OPTIONS", "/api/v1/gists", "https://evil.com", "Access-Control-Request-Method", "POST")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Not expected origin https://evil.com allowed")
+	}
+	w = corsRequest(mr, "OPTIONS", "/api/v1/gists", "http://localhost:8080", "Access-Control-Request-Method", "PUT")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Not expected method PUT allowed")
+	}
+	// Outside the scope, OPTIONS is answered by httprouter itself.
+	router := httprouter.New()
+	router.GET("/api/v1/emojis", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+	expected := httptest.NewRecorder()
+	router.ServeHTTP(expected, httptest.NewRequest("OPTIONS", "/api/v1/emojis", nil))
+	if w := corsRequest(mr, "OPTIONS", "/api/v1/emojis", "https://example.com"); w.Code != expected.Code || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Not expected preflight outside the scope, found Code=%d instead of %d", w.Code, expected.Code)
+	}
+}
+
+func TestCORSActual(t *testing.T) {
+	mr := loadCORSMedeina()
+	w := corsRequest(mr, "GET", "/api/v1/gists", "https://example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" || w.Header().Get("Access-Control-Expose-Headers") != "X-Total-Count" {
+		t.Errorf("Unexpected CORS headers %v", w.Header())
+	}
+	w = corsRequest(mr, "GET", "/api/v1/gists", "https://medeina.dev")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Not expected origin https://medeina.dev allowed, found %v", w.Header())
+	}
+}
+
+func TestCORSCredentialsFromAnyOrigin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected credentials from any origin to be refused")
+		}
+	}()
+	mr := NewMedeina()
+	mr.CORS(&CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, func() {
+		mr.Is("gists", testHandler, GET)
+	})
+}
+
+func TestCORSNested(t *testing.T) {
+	mr := NewMedeina()
+	mr.CORS(&CORSPolicy{AllowedOrigins: []string{"*"}}, func() {
+		mr.Is("emojis", testHandler, GET)
+		mr.CORS(&CORSPolicy{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}, func() {
+			mr.Is("gists", testHandler, GET)
+		})
+	})
+	w := corsRequest(mr, "GET", "/gists", "https://evil.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || len(w.Header()["Vary"]) != 1 {
+		t.Errorf("Expected the inner policy alone, found %v", w.Header())
+	}
+	w = corsRequest(mr, "GET", "/gists", "https://example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Expected the inner policy, found %v", w.Header())
+	}
+	if w := corsRequest(mr, "GET", "/emojis", "https://evil.com"); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected the outer policy, found %v", w.Header())
+	}
+}
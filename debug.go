@@ -0,0 +1,189 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Headers set on responses while the debug mode is enabled.
+const (
+	DebugRouteHeader       = "X-Medeina-Route"
+	DebugMethodScopeHeader = "X-Medeina-Method-Scope"
+	DebugScopeHeader       = "X-Medeina-Scope"
+	DebugNearMissHeader    = "X-Medeina-Near-Miss"
+)
+
+// Enables the debug mode. Responses get annotated with the route pattern,
+// method scope and On chain which handled them, and requests which aren't
+// found, or not allowed for their method, log their near misses: same path
+// with other methods, paths one segment off and trailing-slash variants.
+// If logger is nil, near misses are logged to the standard error.
+// Don't enable it in production, as it exposes the routing tree.
+func (m *Medeina) Debug(logger *log.Logger) {
+	if logger == nil {
+		logger = log.New(os.Stderr, "medeina: ", log.LstdFlags)
+	}
+	m.debug = logger
+	m.router.NotFound = http.HandlerFunc(m.notFound)
+	m.router.MethodNotAllowed = http.HandlerFunc(m.methodNotAllowed)
+}
+
+// Adds the route details to the response headers.
+func annotate(w http.ResponseWriter, route *Route) {
+	header := w.Header()
+	header.Set(DebugRouteHeader, fmt.Sprintf("%s %s", route.Method, route.Path))
+	if route.MethodScope != "" {
+		header.Set(DebugMethodScopeHeader, route.MethodScope)
+	}
+	for _, scope := range route.Scope {
+		header.Add(DebugScopeHeader, scope)
+	}
+}
+
+// Replaces httprouter's not found handler when debugging.
+func (m *Medeina) notFound(w http.ResponseWriter, r *http.Request) {
+	m.logMisses(w, r, "no route")
+	http.NotFound(w, r)
+}
+
+// Replaces httprouter's method not allowed handler when debugging.
+func (m *Medeina) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	m.logMisses(w, r, "method not allowed")
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// Logs the near misses of an unrouted request and adds them to the
+// response headers.
+func (m *Medeina) logMisses(w http.ResponseWriter, r *http.Request, reason string) {
+	if m.debug == nil {
+		return
+	}
+	misses := m.NearMisses(r.Method, r.URL.Path)
+	if len(misses) > 0 {
+		m.debug.Printf("%s for %s %s; near misses: %s", reason, r.Method, r.URL.Path, strings.Join(misses, "; "))
+	} else {
+		m.debug.Printf("%s for %s %s", reason, r.Method, r.URL.Path)
+	}
+	for _, miss := range misses {
+		w.Header().Add(DebugNearMissHeader, miss)
+	}
+}
+
+// Returns the registered routes which almost match a request: the same path
+// with other methods, and paths one segment off or with the trailing slash
+// toggled with the same method.
+func (m *Medeina) NearMisses(method, path string) []string {
+	var misses []string
+	seen := make(map[string]bool)
+	add := func(route *Route, reason string) {
+		key := route.Method + " " + route.Path
+		if !seen[key] {
+			seen[key] = true
+			misses = append(misses, fmt.Sprintf("%s (%s)", key, reason))
+		}
+	}
+	for _, other := range m.methodsInUse() {
+		if other == method {
+			continue
+		}
+		if route, _, ok := m.Match(other, path); ok {
+			add(route, "same path, other method")
+		}
+	}
+	variant := path + "/"
+	if strings.HasSuffix(path, "/") {
+		variant = strings.TrimSuffix(path, "/")
+	}
+	if route, _, ok := m.Match(method, variant); ok {
+		add(route, "trailing slash")
+	}
+	segments := strings.Split(path, "/")
+	for _, route := range m.routes {
+		if route.Method == method && segmentsOff(strings.Split(route.Path, "/"), segments) == 1 {
+			add(route, "one segment off")
+		}
+	}
+	return misses
+}
+
+// Returns the methods of all registered routes.
+func (m *Medeina) methodsInUse() []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, route := range m.routes {
+		if !seen[route.Method] {
+			seen[route.Method] = true
+			methods = append(methods, route.Method)
+		}
+	}
+	return methods
+}
+
+// Counts how many static segments of a pattern differ from a path.
+// Patterns with a different number of segments are never near.
+func segmentsOff(pattern, path []string) int {
+	if len(pattern) != len(path) {
+		return -1
+	}
+	off := 0
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, ":") && path[i] != "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "*") {
+			return off
+		}
+		if segment != path[i] {
+			off++
+		}
+	}
+	return off
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Medeina routes</title></head>
+<body>
+<h1>Medeina routes</h1>
+<table>
+<tr><th>Method</th><th>Pattern</th><th>Method scope</th><th>On chain</th><th>Flags</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td>{{.Path}}</td><td>{{.MethodScope}}</td><td>{{range $i, $s := .Scope}}{{if $i}} &rarr; {{end}}{{$s}}{{end}}</td><td>{{range $i, $f := .Flags}}{{if $i}}, {{end}}{{$f}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Returns a handler listing the tree as text, or as HTML when asked for it
+// through the Accept header or a format=html query param. Mount it wherever
+// you want, e.g.:
+//
+// m.Handler("debug/medeina", m.DebugHandler(), medeina.GET)
+func (m *Medeina) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routes := m.Routes()
+		sort.SliceStable(routes, func(i, j int) bool {
+			if routes[i].Path != routes[j].Path {
+				return routes[i].Path < routes[j].Path
+			}
+			return routes[i].Method < routes[j].Method
+		})
+		if r.URL.Query().Get("format") == "html" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			debugTemplate.Execute(w, routes)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, route := range routes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", route.Method, route.Path, route.MethodScope, strings.Join(route.Scope, " -> "), strings.Join(route.Flags(), ", "))
+		}
+		tw.Flush()
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loadDebugMedeina(buffer *bytes.Buffer) *Medeina {
+	mr := loadMedeina().(*Medeina)
+	mr.Debug(log.New(buffer, "", 0))
+	mr.Handler("debug/medeina", mr.DebugHandler(), GET)
+	return mr
+}
+
+func TestDebugAnnotations(t *testing.T) {
+	mr := loadDebugMedeina(new(bytes.Buffer))
+	r, _ := http.NewRequest("GET", "/repos/imdario/medeina/pulls/1/files", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if route := w.Header().Get(DebugRouteHeader); route != "GET /repos/:owner/:repo/pulls/:number/files" {
+		t.Errorf("Unexpected route header %q", route)
+	}
+	if scope := w.Header().Get(DebugMethodScopeHeader); scope != "GET" {
+		t.Errorf("Unexpected method scope header %q", scope)
+	}
+	if scopes := w.Header()[DebugScopeHeader]; len(scopes) != 2 || scopes[1] != "pulls/:number" {
+		t.Errorf("Unexpected scope headers %q", scopes)
+	}
+}
+
+func TestDebugNearMisses(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	mr := loadDebugMedeina(buffer)
+	r, _ := http.NewRequest("GET", "/repos/imdario/medeina/stargzrs", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected not found, found Code=%d", w.Code)
+	}
+	misses := w.Header()[DebugNearMissHeader]
+	expected := "GET /repos/:owner/:repo/stargazers (one segment off)"
+	found := false
+	for _, miss := range misses {
+		found = found || miss == expected
+	}
+	if !found {
+		t.Errorf("Expected near miss %q, found %q", expected, misses)
+	}
+	if !strings.Contains(buffer.String(), expected) {
+		t.Errorf("Expected near miss logged, found %q", buffer.String())
+	}
+	buffer.Reset()
+	r, _ = http.NewRequest("PATCH", "/gists/1", nil)
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || len(w.Header()[DebugNearMissHeader]) == 0 {
+		t.Errorf("Expected near misses of a method not allowed, found Code=%d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(buffer.String(), "method not allowed for PATCH /gists/1") {
+		t.Errorf("Expected method not allowed logged, found %q", buffer.String())
+	}
+	misses = mr.NearMisses("PATCH", "/gists/1")
+	if len(misses) != 2 || misses[0] != "GET /gists/:id (same path, other method)" {
+		t.Errorf("Unexpected near misses %q", misses)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	mr := loadDebugMedeina(new(bytes.Buffer))
+	r, _ := http.NewRequest("GET", "/debug/medeina", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "/repos/:owner/:repo/pulls/:number/files") {
+		t.Errorf("Expected route listed, found %q", w.Body.String())
+	}
+	r, _ = http.NewRequest("GET", "/debug/medeina?format=html", nil)
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if !strings.Contains(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "<td>/gists/:id</td>") {
+		t.Errorf("Expected HTML listing, found %q", w.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Deprecation tells when something was deprecated, when it goes away and
+// what replaces it.
+type Deprecation struct {
+	// When it was deprecated. If zero, it is sent as "true", as older
+	// drafts of the Deprecation header did.
+	Date time.Time
+	// When it stops being served, if known.
+	Sunset time.Time
+	// Route replacing it, sent in a successor-version link. Params in it,
+	// like :owner, are filled with the ones of the request. It can also be
+	// an absolute URL.
+	Successor string
+	// Answers 410 Gone once the sunset date has passed.
+	Gone bool
+}
+
+// DeprecatedRoute is a deprecated route with the number of requests it
+// got since it was registered.
+type DeprecatedRoute struct {
+	Route
+	Hits uint64
+}
+
+// Sets the Deprecation and Sunset headers, and the successor link if any.
+func (d *Deprecation) setHeaders(header http.Header, ps Params) {
+	if d.Date.IsZero() {
+		header.Set("Deprecation", "true")
+	} else {
+		header.Set("Deprecation", "@"+strconv.FormatInt(d.Date.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		successor := d.Successor
+		if !strings.Contains(successor, "://") {
+			successor = (&Route{Path: successor}).expand(ps)
+		}
+		header.Add("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+}
+
+// Tags a route as deprecated, unless a deeper scope already did, counting
+// its hits and answering with deprecation headers.
+func (d *Deprecation) middleware(route *Route, handle httprouter.Handle) httprouter.Handle {
+	if route.Deprecation != nil {
+		return handle
+	}
+	route.Deprecation = d
+	route.hits = new(atomic.Uint64)
+	hits := route.hits
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		hits.Add(1)
+		d.setHeaders(w.Header(), Params(ps))
+		if d.Gone && !d.Sunset.IsZero() && time.Now().After(d.Sunset) {
+			http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
+// Deprecates every route registered inside the closure, which can be a
+// single one. Deeper scopes override the outer ones.
+func (m *Medeina) Deprecate(deprecation *Deprecation, handle Handle) {
+	m.Use(deprecation.middleware, handle)
+}
+
+// Returns the deprecated routes, in registration order, with their hits.
+func (m *Medeina) DeprecatedRoutes() []DeprecatedRoute {
+	var routes []DeprecatedRoute
+	for _, route := range m.routes {
+		if route.Deprecation == nil {
+			continue
+		}
+		routes = append(routes, DeprecatedRoute{Route: *route, Hits: route.hits.Load()})
+	}
+	return routes
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func loadDeprecatedMedeina() *Medeina {
+	text := func(body string) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			fmt.Fprint(w, body)
+		}
+	}
+	mr := NewMedeina()
+	mr.GET(func() {
+		mr.On("repos/:owner", func() {
+			mr.Deprecate(&Deprecation{
+				Date:      time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC),
+				Successor: "/users/:owner/repos",
+			}, func() {
+				mr.Is("", text("repos"))
+				mr.Is("starred", text("starred"))
+				mr.Deprecate(&Deprecation{
+					Sunset: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+					Gone:   true,
+				}, func() {
+					mr.Is("watched", text("watched"))
+				})
+			})
+		})
+		mr.Is("users/:owner/repos", text("user repos"))
+	})
+	return mr
+}
+
+func TestDeprecate(t *testing.T) {
+	mr := loadDeprecatedMedeina()
+	for i := 0; i < 3; i++ {
+		r, _ := http.NewRequest("GET", "/repos/imdario", nil)
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "@1401580800" {
+			t.Errorf("Expected a deprecated response, found %d %q", w.Code, w.Header().Get("Deprecation"))
+		}
+		if link := w.Header().Get("Link"); link != `</users/imdario/repos>; rel="successor-version"` {
+			t.Errorf("Expected a successor link, found %q", link)
+		}
+	}
+	r, _ := http.NewRequest("GET", "/repos/imdario/watched", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusGone || w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") != "Thu, 01 Jan 2015 00:00:00 GMT" {
+		t.Errorf("Expected 410 Gone after the sunset, found %d %q %q", w.Code, w.Header().Get("Deprecation"), w.Header().Get("Sunset"))
+	}
+	r, _ = http.NewRequest("GET", "/users/imdario/repos", nil)
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected no deprecation outside the scope, found %q", w.Header().Get("Deprecation"))
+	}
+	hits := make(map[string]uint64)
+	for _, route := range mr.DeprecatedRoutes() {
+		hits[route.Path] = route.Hits
+	}
+	expected := map[string]uint64{"/repos/:owner": 3, "/repos/:owner/starred": 0, "/repos/:owner/watched": 1}
+	if fmt.Sprint(hits) != fmt.Sprint(expected) {
+		t.Errorf("Expected deprecated routes %v, found %v", expected, hits)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditional configures conditional requests for a scope.
+type Conditional struct {
+	// Generates weak ETags instead of strong ones.
+	Weak bool
+	// Returns the validators of the current representation of a resource,
+	// for PUT, PATCH and DELETE routes to check their preconditions. The
+	// ETag may be given bare, as to SetETag, or quoted. An empty ETag and
+	// a zero time tell there's no current representation.
+	Current func(r *http.Request, ps Params) (etag string, modified time.Time)
+}
+
+// Sets the ETag of a response. Use it in handlers which know the version
+// of their resource, so the response doesn't need to be hashed.
+func SetETag(w http.ResponseWriter, tag string, weak bool) {
+	tag = fmt.Sprintf("%q", strings.Trim(tag, `"`))
+	if weak {
+		tag = "W/" + tag
+	}
+	w.Header().Set("ETag", tag)
+}
+
+// Sets the Last-Modified date of a response.
+func SetLastModified(w http.ResponseWriter, modified time.Time) {
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+}
+
+// Hashes a body into an ETag.
+func hashETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// Compares two entity tags. Weak comparison ignores the W/ prefix.
+func matchETag(a, b string, weak bool) bool {
+	if weak {
+		return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+	}
+	return a == b && !strings.HasPrefix(a, "W/")
+}
+
+// Tells if any of the tags in an If-Match or If-None-Match header matches.
+func matchETags(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || matchETag(tag, etag, weak) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if a response with the given validators is still fresh for the
+// conditional headers of a GET or HEAD request.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchETags(inm, etag, true)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && lastModified != "" {
+		since, err := http.ParseTime(ims)
+		modified, err2 := http.ParseTime(lastModified)
+		return err == nil && err2 == nil && !modified.After(since)
+	}
+	return false
+}
+
+// Tells if the If-Match and If-Unmodified-Since preconditions of an unsafe
+// request hold for the current representation. Weak comparison is meant
+// for representations whose tags were weakened by Compress.
+func preconditionsHold(r *http.Request, etag string, modified time.Time, weak bool) bool {
+	exists := etag != "" || !modified.IsZero()
+	if im := r.Header.Get("If-Match"); im != "" {
+		if strings.TrimSpace(im) == "*" {
+			return exists
+		}
+		return etag != "" && matchETags(im, etag, weak)
+	}
+	if ius := r.Header.Get("If-Unmodified-Since"); ius != "" {
+		since, err := http.ParseTime(ius)
+		return !modified.IsZero() && err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	return true
+}
+
+// Adds ETags to the responses of GET routes registered inside the closure,
+// answering If-None-Match and If-Modified-Since with 304 Not Modified.
+// Responses are hashed unless handlers set their own validators with
+// SetETag or SetLastModified.
+// PUT, PATCH and DELETE routes check If-Match and If-Unmodified-Since
+// against the validators returned by Current, and answer 412 Precondition
+// Failed when they don't hold, or when Current isn't set. As Compress
+// weakens the ETags of the responses it compresses, If-Match compares them
+// weakly if the GET route on the same path is compressed.
+func (m *Medeina) Conditional(conditional *Conditional, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.Conditional = true
+		switch route.Method {
+		case "GET", "HEAD":
+			return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				cw := &conditionalWriter{
+					ResponseWriter: w,
+					request:        r,
+					weak:           conditional.Weak,
+				}
+				handle(cw, r, ps)
+				cw.finish()
+			}
+		case "PUT", "PATCH", "DELETE":
+			return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				if r.Header.Get("If-Match") != "" || r.Header.Get("If-Unmodified-Since") != "" {
+					var etag string
+					var modified time.Time
+					if conditional.Current != nil {
+						etag, modified = conditional.Current(r, Params(ps))
+					}
+					if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+						etag = fmt.Sprintf("%q", etag)
+					}
+					if !preconditionsHold(r, etag, modified, m.compressed(route.Path)) {
+						http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+						return
+					}
+				}
+				handle(w, r, ps)
+			}
+		}
+		return handle
+	}, handle)
+}
+
+// Tells if the GET route on a path is compressed.
+func (m *Medeina) compressed(path string) bool {
+	for _, route := range m.routes {
+		if route.Method == "GET" && route.Path == path {
+			return route.Compression != nil && !route.Compression.Disabled
+		}
+	}
+	return false
+}
+
+// Buffers a response to hash it and answer conditional requests. Flushing
+// gives up on it, streaming the response as it is.
+type conditionalWriter struct {
+	http.ResponseWriter
+	request   *http.Request
+	weak      bool
+	status    int
+	buffer    bytes.Buffer
+	streaming bool
+}
+
+func (cw *conditionalWriter) WriteHeader(code int) {
+	if cw.streaming || code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if cw.streaming {
+		return cw.ResponseWriter.Write(b)
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	return cw.buffer.Write(b)
+}
+
+func (cw *conditionalWriter) Flush() {
+	if !cw.streaming {
+		cw.streaming = true
+		if cw.status != 0 {
+			cw.ResponseWriter.WriteHeader(cw.status)
+		}
+		cw.ResponseWriter.Write(cw.buffer.Bytes())
+		cw.buffer.Reset()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Sends the buffered response, or a 304 if the client copy is still fresh.
+func (cw *conditionalWriter) finish() {
+	if cw.streaming || cw.status == 0 {
+		return
+	}
+	header := cw.ResponseWriter.Header()
+	if cw.status == http.StatusOK {
+		etag := header.Get("ETag")
+		if etag == "" {
+			etag = hashETag(cw.buffer.Bytes(), cw.weak)
+			header.Set("ETag", etag)
+		}
+		if notModified(cw.request, etag, header.Get("Last-Modified")) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			cw.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(cw.buffer.Bytes())
+}
+
+func (cw *conditionalWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
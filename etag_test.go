@@ -0,0 +1,135 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var gistModified = time.Date(2014, time.November, 3, 10, 0, 0, 0, time.UTC)
+
+func loadConditionalMedeina() *Medeina {
+	gists := map[string]string{"1": "Medeina", "2": "Medeinė"}
+	mr := NewMedeina()
+	mr.On("gists/:id", func() {
+		current := func(r *http.Request, ps Params) (string, time.Time) {
+			if gist, ok := gists[ps.ByName("id")]; ok {
+				return hashETag([]byte(gist), false), time.Time{}
+			}
+			return "", time.Time{}
+		}
+		mr.Conditional(&Conditional{Current: current}, func() {
+			mr.Is("", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				fmt.Fprint(w, gists[ps.ByName("id")])
+			}, GET)
+			mr.Is("", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				gists[ps.ByName("id")] = "updated"
+			}, PUT)
+			mr.Is("star", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				SetETag(w, "v"+ps.ByName("id"), true)
+				SetLastModified(w, gistModified)
+				fmt.Fprint(w, "starred")
+			}, GET)
+		})
+	})
+	return mr
+}
+
+func conditionalRequest(mr *Medeina, method, path string, headers ...string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestConditionalGET(t *testing.T) {
+	mr := loadConditionalMedeina()
+	w := conditionalRequest(mr, "GET", "/gists/1")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Body.String() != "Medeina" {
+		t.Fatalf("Expected hashed ETag, found Code=%d %v", w.Code, w.Header())
+	}
+	if w := conditionalRequest(mr, "GET", "/gists/1", "If-None-Match", `"other", `+etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 for matching ETag, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "GET", "/gists/2", "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for another gist, found Code=%d", w.Code)
+	}
+	w = conditionalRequest(mr, "GET", "/gists/1/star", "If-None-Match", `"v1"`)
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != `W/"v1"` {
+		t.Errorf("Expected 304 for handler ETag, found Code=%d %v", w.Code, w.Header())
+	}
+	since := gistModified.Add(time.Hour).Format(http.TimeFormat)
+	if w := conditionalRequest(mr, "GET", "/gists/1/star", "If-Modified-Since", since); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for If-Modified-Since, found Code=%d", w.Code)
+	}
+	since = gistModified.Add(-time.Hour).Format(http.TimeFormat)
+	if w := conditionalRequest(mr, "GET", "/gists/1/star", "If-Modified-Since", since); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for older If-Modified-Since, found Code=%d", w.Code)
+	}
+}
+
+func TestConditionalPUT(t *testing.T) {
+	mr := loadConditionalMedeina()
+	etag := conditionalRequest(mr, "GET", "/gists/1").Header().Get("ETag")
+	if w := conditionalRequest(mr, "PUT", "/gists/1", "If-Match", `"stale"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for stale If-Match, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gists/1", "If-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for current If-Match, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gists/1", "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 once updated, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gists/2", "If-Match", "*"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for If-Match *, found Code=%d", w.Code)
+	}
+}
+
+func TestConditionalCompressed(t *testing.T) {
+	version := 1
+	mr := NewMedeina()
+	mr.Compress(&Compression{MinSize: -1}, func() {
+		mr.Conditional(&Conditional{Current: func(r *http.Request, ps Params) (string, time.Time) {
+			return fmt.Sprintf("v%d", version), time.Time{}
+		}}, func() {
+			mr.Is("gist", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				SetETag(w, fmt.Sprintf("v%d", version), false)
+				fmt.Fprint(w, "Medeina")
+			}, GET)
+			mr.Is("gist", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				version++
+			}, PUT)
+		})
+	})
+	etag := conditionalRequest(mr, "GET", "/gist", "Accept-Encoding", "gzip").Header().Get("ETag")
+	if etag != `W/"v1"` {
+		t.Fatalf("Expected a weakened ETag, found %q", etag)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gist", "If-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for the compressed ETag, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gist", "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 once updated, found Code=%d", w.Code)
+	}
+}
+
+func TestConditionalWithoutCurrent(t *testing.T) {
+	mr := NewMedeina()
+	mr.Conditional(&Conditional{}, func() {
+		mr.Is("gist", testHandler, PUT)
+	})
+	if w := conditionalRequest(mr, "PUT", "/gist", "If-Match", `"v1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 without validators, found Code=%d", w.Code)
+	}
+	if w := conditionalRequest(mr, "PUT", "/gist"); w.Code != http.StatusOK {
+		t.Errorf("Expected unconditional requests to pass, found Code=%d", w.Code)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"strconv"
+	"strings"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity string
+
+const (
+	// Errors are routes httprouter refuses. Trees panic on them, unless
+	// created WithLint.
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Checks run while building trees.
+const (
+	// Routes httprouter can't tell apart from others, like /users/:id/x
+	// and /users/new/:x.
+	CheckConflict = "conflict"
+	// Routes matching every path of another one, like /users/:id and
+	// /users/new.
+	CheckShadowed = "shadowed"
+	// Params with different names at the same depth, like /repos/:id and
+	// /repos/:name. They are errors for the same method, as httprouter
+	// refuses them, and warnings for different ones.
+	CheckParamNames = "param-names"
+	// Routes registered twice.
+	CheckDuplicate = "duplicate"
+	// On("") scopes, adding an empty segment to the paths in them.
+	CheckEmptyOn = "empty-on"
+	// Is calls outside any method scope and without methods.
+	CheckNoMethod = "no-method"
+)
+
+// Location tells where a diagnostic was found: a route, or a scope if it
+// has no method.
+type Location struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+	// Chain of On subpaths leading to it.
+	Scope []string `json:"scope"`
+}
+
+func (l Location) String() string {
+	s := l.Path
+	if l.Method != "" {
+		s = l.Method + " " + s
+	}
+	if len(l.Scope) > 0 {
+		quoted := make([]string, len(l.Scope))
+		for i, subpath := range l.Scope {
+			quoted[i] = strconv.Quote(subpath)
+		}
+		s += " (on " + strings.Join(quoted, " -> ") + ")"
+	}
+	return s
+}
+
+// Diagnostic is a problem found while building a tree.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Location
+	// Route it clashes with, if any.
+	Other   *Location `json:"other,omitempty"`
+	Message string    `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Method == "" && d.Path == "" && len(d.Scope) == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.Severity, d.Check, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", d.Severity, d.Check, d.Location, d.Message)
+}
+
+// RouteError is the panic raised by routes with errors, unless linting.
+type RouteError struct {
+	Diagnostic
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("you cannot register %s: %s", e.Location, e.Message)
+}
+
+// Makes the tree record errors as diagnostics instead of panicking,
+// leaving out the routes causing them, so a whole tree can be checked at
+// once. Warnings are recorded anyway.
+func WithLint() Option {
+	return func(m *Medeina) {
+		m.linting = true
+	}
+}
+
+// Returns the diagnostics found while building the tree, in the order they
+// were found.
+func (m *Medeina) Diagnostics() []Diagnostic {
+	return append([]Diagnostic(nil), m.diagnostics...)
+}
+
+// Records a diagnostic, panicking on errors unless linting.
+func (m *Medeina) report(d Diagnostic) {
+	if d.Severity == SeverityError && !m.linting {
+		panic(&RouteError{d})
+	}
+	m.diagnostics = append(m.diagnostics, d)
+}
+
+// Warns about empty subpaths, as they end up as // in the paths of the
+// scope, or as a trailing slash.
+func (m *Medeina) checkSubpath(path string) {
+	if path == "" {
+		m.report(Diagnostic{
+			Severity: SeverityWarning,
+			Check:    CheckEmptyOn,
+			Location: m.scopeLocation(),
+			Message:  `empty subpath, use Is("") to route the scope itself`,
+		})
+	}
+}
+
+// Returns where the current scope is.
+func (m *Medeina) scopeLocation() Location {
+	return Location{Path: joinDeque(m.path), Scope: sliceDeque(m.path)}
+}
+
+func routeLocation(route *Route) Location {
+	return Location{Method: route.Method, Path: route.Path, Scope: route.Scope}
+}
+
+// Adds a route to the router, checking it against the ones already there.
+func (m *Medeina) add(route *Route, handle httprouter.Handle) {
+	warned := false
+	for _, other := range m.routes {
+		check, depth := clash(route.Path, other.Path)
+		if check == "" || (route.Method != other.Method && (check != CheckParamNames || warned)) {
+			continue
+		}
+		d := Diagnostic{
+			Severity: SeverityError,
+			Check:    check,
+			Location: routeLocation(route),
+			Other:    &Location{Method: other.Method, Path: other.Path, Scope: other.Scope},
+		}
+		segment := func(path string) string {
+			if segment := pathSegments(path)[depth]; segment != "" {
+				return segment
+			}
+			return "empty segment"
+		}
+		switch check {
+		case CheckDuplicate:
+			d.Message = fmt.Sprintf("already registered as %s", d.Other)
+		case CheckParamNames:
+			d.Message = fmt.Sprintf("param %s is named %s in %s", segment(route.Path), segment(other.Path), d.Other)
+			if route.Method != other.Method {
+				d.Severity = SeverityWarning
+				m.report(d)
+				warned = true
+				continue
+			}
+		case CheckShadowed:
+			if covers(pathSegments(other.Path), pathSegments(route.Path), depth) {
+				d.Message = fmt.Sprintf("shadowed by %s of %s", segment(other.Path), d.Other)
+			} else {
+				d.Message = fmt.Sprintf("%s shadows %s", segment(route.Path), d.Other)
+			}
+		default:
+			d.Message = fmt.Sprintf("%s conflicts with %s of %s", segment(route.Path), segment(other.Path), d.Other)
+		}
+		m.report(d)
+		return
+	}
+	// Conflicts not foreseen above are still reported as such.
+	defer func() {
+		if p := recover(); p != nil {
+			m.report(Diagnostic{
+				Severity: SeverityError,
+				Check:    CheckConflict,
+				Location: routeLocation(route),
+				Message:  fmt.Sprint(p),
+			})
+		}
+	}()
+	m.router.Handle(route.Method, route.Path, handle)
+	m.routes = append(m.routes, route)
+}
+
+// Returns the segments of a path, without the empty one before its first
+// slash.
+func pathSegments(path string) []string {
+	return strings.Split(path, "/")[1:]
+}
+
+// Returns the kind of wildcard a segment is, : or *, or zero for static
+// segments.
+func wildcard(segment string) byte {
+	if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+		return segment[0]
+	}
+	return 0
+}
+
+// Compares two paths of the same method as httprouter does, returning the
+// check they fail, if any, and the depth of the segment failing it.
+func clash(path, other string) (string, int) {
+	a, b := pathSegments(path), pathSegments(other)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		wa, wb := wildcard(a[i]), wildcard(b[i])
+		switch {
+		case wa == 0 && wb == 0:
+			if a[i] != b[i] {
+				return "", 0
+			}
+		case wa == ':' && wb == ':':
+			if a[i] != b[i] {
+				return CheckParamNames, i
+			}
+		case wa == '*' || wb == '*':
+			if a[i] != b[i] {
+				return overlap(a, b, i), i
+			}
+		default:
+			// A trailing slash is the node holding the param, not a
+			// sibling of it.
+			if (wa == 0 && a[i] == "" && i == len(a)-1) || (wb == 0 && b[i] == "" && i == len(b)-1) {
+				return "", 0
+			}
+			return overlap(a, b, i), i
+		}
+	}
+	if len(a) == len(b) {
+		return CheckDuplicate, len(a) - 1
+	}
+	return "", 0
+}
+
+// Tells if the paths diverging at the given depth shadow one another, or
+// just conflict.
+func overlap(a, b []string, depth int) string {
+	if covers(a, b, depth) || covers(b, a, depth) {
+		return CheckShadowed
+	}
+	return CheckConflict
+}
+
+// Tells if every path matched by b from the given depth is matched by a.
+func covers(a, b []string, depth int) bool {
+	for i := depth; i < len(a); i++ {
+		switch {
+		case wildcard(a[i]) == '*':
+			return true
+		case i >= len(b) || wildcard(b[i]) == '*':
+			return false
+		case wildcard(a[i]) == ':':
+			if b[i] == "" {
+				return false
+			}
+		case a[i] != b[i]:
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
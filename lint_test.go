@@ -0,0 +1,86 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	mr := NewMedeina(WithLint())
+	mr.On("repos", func() {
+		mr.On(":owner", func() {
+			mr.GET(func() {
+				mr.Is(":repo", testHandler)
+				mr.Is(":name", testHandler)
+				mr.Is(":repo", testHandler)
+				mr.Is(":repo/", testHandler)
+			})
+			mr.Is(":name", testHandler, POST)
+		})
+		mr.On("", func() {
+			mr.Is("new", testHandler)
+		})
+	})
+	mr.On("users", func() {
+		mr.GET(func() {
+			mr.Is(":id", testHandler)
+			mr.Is("new", testHandler)
+			mr.Is("*filepath", testHandler)
+			mr.Is("new/:x", testHandler)
+		})
+	})
+	mr.Is("static/*filepath", testHandler, GET)
+	mr.Is("static/:file/x", testHandler, GET)
+	expected := []string{
+		`error: param-names: GET /repos/:owner/:name (on "repos" -> ":owner"): param :name is named :repo in GET /repos/:owner/:repo (on "repos" -> ":owner")`,
+		`error: duplicate: GET /repos/:owner/:repo (on "repos" -> ":owner"): already registered as GET /repos/:owner/:repo (on "repos" -> ":owner")`,
+		`warning: param-names: POST /repos/:owner/:name (on "repos" -> ":owner"): param :name is named :repo in GET /repos/:owner/:repo (on "repos" -> ":owner")`,
+		`warning: empty-on: /repos (on "repos" -> ""): empty subpath, use Is("") to route the scope itself`,
+		`error: no-method: /repos//new (on "repos" -> ""): outside any method scope and without methods`,
+		`error: shadowed: GET /users/new (on "users"): shadowed by :id of GET /users/:id (on "users")`,
+		`error: shadowed: GET /users/*filepath (on "users"): *filepath shadows GET /users/:id (on "users")`,
+		`error: conflict: GET /users/new/:x (on "users"): new conflicts with :id of GET /users/:id (on "users")`,
+		`error: shadowed: GET /static/:file/x: shadowed by *filepath of GET /static/*filepath`,
+	}
+	diagnostics := mr.Diagnostics()
+	if len(diagnostics) != len(expected) {
+		t.Errorf("Expected %d diagnostics, found %d: %v", len(expected), len(diagnostics), diagnostics)
+	}
+	for i := 0; i < len(diagnostics) && i < len(expected); i++ {
+		if diagnostics[i].String() != expected[i] {
+			t.Errorf("Expected %s, found %s", expected[i], diagnostics[i])
+		}
+	}
+	for _, route := range mr.Routes() {
+		if route.Path == "/users/new" || (route.Path == "/repos/:owner/:name" && route.Method == GET) {
+			t.Errorf("Expected routes with errors to be left out, found %s %s", route.Method, route.Path)
+		}
+	}
+	if _, _, ok := mr.Match("GET", "/repos/imdario/medeina/"); !ok {
+		t.Errorf("Expected trailing slashes next to params to be registered")
+	}
+}
+
+func TestDiagnosticsConflict(t *testing.T) {
+	mr := NewMedeina(WithLint())
+	mr.GET(func() {
+		mr.Is("users/:id/x", testHandler)
+		mr.Is("users/new/:x", testHandler)
+	})
+	diagnostics := mr.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Check != CheckConflict || diagnostics[0].Other.Path != "/users/:id/x" {
+		t.Errorf("Expected a conflict with /users/:id/x, found %v", diagnostics)
+	}
+	defer func() {
+		p := recover()
+		if p == nil || !strings.HasPrefix(p.(error).Error(), `you cannot register GET /users/new/:x: new conflicts with :id`) {
+			t.Errorf("Expected a panic telling the conflict, found %v", p)
+		}
+	}()
+	mr = NewMedeina()
+	mr.GET(func() {
+		mr.Is("users/:id/x", testHandler)
+		mr.Is("users/new/:x", testHandler)
+	})
+}
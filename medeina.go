@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/oleiade/lane"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -21,14 +23,29 @@ type router struct {
 // Allow it to be part of your chain of HTTP Handlers and she will handle
 // all those messy branches that your once-used-to-be-simple router got.
 type Medeina struct {
-	router  *router
-	methods *lane.Stack
-	path    *lane.Deque
+	router      *router
+	methods     *lane.Stack
+	path        *lane.Deque
+	middlewares *lane.Deque
+	routes      []*Route
+	debug       *log.Logger
+	metrics     MetricsHook
+	tracer      Tracer
+	versioning  *Versioning
+	versionings []*Versioning
+	requestIDs  *RequestIDs
+	proxies     *TrustedProxies
+	linting     bool
+	diagnostics []Diagnostic
 }
 
 // Medeina closures definition.
 type Handle func()
 
+// Middlewares wrap the handle of every route registered inside their scope.
+// They get the route being registered, so they can adapt to it.
+type Middleware func(route *Route, handle httprouter.Handle) httprouter.Handle
+
 // HTTP Methods available as constants.
 // We could use strings but it was cleaner to force
 // specefic values in an enum-like fashion.
@@ -36,12 +53,22 @@ type Method string
 
 type Params httprouter.Params
 
+// Returns the value of the first param which key matches the given name.
+// If no matching param is found, an empty string is returned.
+func (ps Params) ByName(name string) string {
+	return httprouter.Params(ps).ByName(name)
+}
+
 const (
 	GET    = "GET"
 	POST   = "POST"
 	PUT    = "PUT"
 	PATCH  = "PATCH"
 	DELETE = "DELETE"
+	// Not included in Methods, as they're usually answered by Medeina
+	// itself.
+	HEAD    = "HEAD"
+	OPTIONS = "OPTIONS"
 )
 
 var Methods = []Method{GET, POST, PUT, PATCH, DELETE}
@@ -68,6 +95,28 @@ func joinDeque(s *lane.Deque) string {
 	return buffer.String()
 }
 
+// Calls f for each element of a deque, from first to last, leaving it
+// untouched.
+func eachDeque(s *lane.Deque, f func(interface{})) {
+	bDeque := lane.NewDeque()
+	for e := s.Shift(); e != nil; e = s.Shift() {
+		f(e)
+		bDeque.Append(e)
+	}
+	for e := bDeque.Shift(); e != nil; e = bDeque.Shift() {
+		s.Append(e)
+	}
+}
+
+// Copies a deque into a slice of strings, leaving it untouched.
+func sliceDeque(s *lane.Deque) []string {
+	var slice []string
+	eachDeque(s, func(e interface{}) {
+		slice = append(slice, fmt.Sprintf("%v", e))
+	})
+	return slice
+}
+
 var (
 	// Make sure this conforms with the http.Handle interface
 	// as in julienschmidt/httprouter.
@@ -75,14 +124,19 @@ var (
 )
 
 // Returns a new initialized Medeina tree routing with default httprouter's one.
-func NewMedeina() *Medeina {
-	return &Medeina{
+func NewMedeina(options ...Option) *Medeina {
+	m := &Medeina{
 		router: &router{
 			httprouter.New(),
 		},
-		methods: lane.NewStack(),
-		path:    lane.NewDeque(),
+		methods:     lane.NewStack(),
+		path:        lane.NewDeque(),
+		middlewares: lane.NewDeque(),
 	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
 }
 
 // Core logic of handling routes in a tree.
@@ -119,11 +173,20 @@ func (m *Medeina) DELETE(handles Handle) {
 	m.handle("DELETE", handles)
 }
 
+// Applies a middleware to every route registered inside the closure.
+// Middlewares from outer scopes run before the ones from inner scopes.
+func (m *Medeina) Use(middleware Middleware, handle Handle) {
+	m.middlewares.Append(middleware)
+	handle()
+	m.middlewares.Pop()
+}
+
 // Adds a new subpath to the current context. Everything under the
 // closure will use all the previously set path as root for their
 // URLs.
 func (m *Medeina) On(path string, handle Handle) {
 	m.path.Append(path)
+	m.checkSubpath(path)
 	handle()
 	m.path.Pop()
 }
@@ -132,6 +195,7 @@ func (m *Medeina) On(path string, handle Handle) {
 // This will be useful to split routes definition in several functions.
 func (m *Medeina) OnFunc(path string, handle func(*Medeina)) {
 	m.path.Append(path)
+	m.checkSubpath(path)
 	handle(m)
 	m.path.Pop()
 }
@@ -170,15 +234,23 @@ func (m *Medeina) Is(path string, handle httprouter.Handle, methods ...Method) {
 	// If any method is provided, it overrides the default one.
 	if len(methods) > 0 {
 		for _, method := range methods {
-			sm := string(method)
-			m.router.Handle(sm, fullPath, handle)
+			m.register(method, fullPath, handle)
 		}
 	} else {
 		method := m.methods.Head()
 		if method == nil {
-			panic(fmt.Errorf("you cannot set an endpoint outside a HTTP method scope or without passing methods by parameter"))
+			if !m.linting {
+				panic(fmt.Errorf("you cannot set an endpoint outside a HTTP method scope or without passing methods by parameter"))
+			}
+			m.report(Diagnostic{
+				Severity: SeverityError,
+				Check:    CheckNoMethod,
+				Location: Location{Path: fullPath, Scope: sliceDeque(m.path)},
+				Message:  "outside any method scope and without methods",
+			})
+			return
 		}
-		m.router.Handle(string(method.(Method)), fullPath, handle)
+		m.register(method.(Method), fullPath, handle)
 	}
 }
 
@@ -186,22 +258,35 @@ func (m *Medeina) Is(path string, handle httprouter.Handle, methods ...Method) {
 // There is no equivalent functions for specific HTTP methods, so you must use
 // this in order to add standard http.Handlers.
 func (m *Medeina) Handler(path string, handle http.Handler, methods ...Method) {
-	m.path.Append(path)
-	fullPath := joinDeque(m.path)
-	m.path.Pop()
-	// If any method is provided, it overrides the default one.
-	if len(methods) > 0 {
-		for _, method := range methods {
-			sm := string(method)
-			m.router.Handler(sm, fullPath, handle)
-		}
-	} else {
-		method := m.methods.Head()
-		if method == nil {
-			panic(fmt.Errorf("you cannot set an endpoint outside a HTTP method scope or without passing methods by parameter"))
-		}
-		m.router.Handler(string(method.(Method)), fullPath, handle)
+	m.Is(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handle.ServeHTTP(w, r)
+	}, methods...)
+}
+
+// Registers the handle in the router, keeping track of the route so it
+// can be introspected later.
+func (m *Medeina) register(method Method, fullPath string, handle httprouter.Handle) {
+	route := &Route{
+		Method: string(method),
+		Path:   fullPath,
+		Scope:  sliceDeque(m.path),
+	}
+	if scope := m.methods.Head(); scope != nil {
+		route.MethodScope = string(scope.(Method))
+	}
+	var middlewares []Middleware
+	eachDeque(m.middlewares, func(e interface{}) {
+		middlewares = append(middlewares, e.(Middleware))
+	})
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handle = middlewares[i](route, handle)
+	}
+	if v := m.versioning; v != nil {
+		// Registered once the whole version is defined.
+		v.pending = append(v.pending, &versionedRoute{route, m.dispatch(route, handle), v.since})
+		return
 	}
+	m.add(route, m.dispatch(route, handle))
 }
 
 // Utility function to use with http.Handler compatible routers. Modifies
@@ -222,5 +307,24 @@ func HandlerPathPrefix(prefix string, handle http.Handler) http.Handler {
 
 // Makes the routing tree implement the http.Handler interface.
 func (m *Medeina) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.proxies != nil {
+		r = m.proxies.Resolve(r)
+	}
+	if m.requestIDs != nil {
+		r = m.requestIDs.assign(w, r)
+	}
+	if len(m.versionings) > 0 {
+		var ok bool
+		if r, ok = m.selectVersion(w, r); !ok {
+			return
+		}
+	}
+	if m.tracer != nil {
+		rw, r, span := m.startSpan(w, r)
+		defer span.End()
+		m.router.ServeHTTP(rw, r)
+		span.SetAttribute("http.response.status_code", strconv.Itoa(rw.Status()))
+		return
+	}
 	m.router.ServeHTTP(w, r)
 }
@@ -0,0 +1,509 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+/*
+Package medeinaconfig loads Medeina trees from YAML or JSON documents, for
+teams which rather not write Go closures. Handlers and middlewares are
+referenced by name and resolved against a Registry.
+
+	routes:
+	  - on: repos/:owner/:repo
+	    use: [auth]
+	    methods: [GET]
+	    routes:
+	      - is: ""
+	        handler: getRepo
+	      - is: topics
+	        methods: [GET, PUT]
+	        handler: topics
+
+Each entry either adds a subpath with on, containing more routes, or
+registers a route with is. Both may set methods and use middlewares. The
+tree built is the same the closure API would build: on maps to On, use to
+Use or a named scope, and methods to GET, POST, etc. closures or to the
+methods given to Is.
+
+Routes may also have a name and the Go types of their params, like int for
+:number. They are ignored when building trees, being meant for code
+generators reading Endpoints.
+*/
+package medeinaconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/imdario/medeina"
+	"github.com/julienschmidt/httprouter"
+	"go/token"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Scope applies a feature taking a closure, like Authenticate or
+// RateLimit, to the routes registered inside handle.
+type Scope func(m *medeina.Medeina, handle medeina.Handle)
+
+// Registry holds the values documents refer to by name.
+type Registry struct {
+	Handles     map[string]httprouter.Handle
+	Handlers    map[string]http.Handler
+	Middlewares map[string]medeina.Middleware
+	Scopes      map[string]Scope
+}
+
+// Error is a problem found in a document, with its position.
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// Errors lists every problem found in a document.
+type Errors []*Error
+
+func (es Errors) Error() string {
+	messages := make([]string, len(es))
+	for i, e := range es {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Endpoint is a route registered by a document, along with the metadata
+// given to it.
+type Endpoint struct {
+	medeina.Route
+	// Go identifier naming the route, if any.
+	Name string
+	// Go types of the path params, by param name. Missing params are strings.
+	Params map[string]string
+}
+
+// Go types which path params can be declared with.
+var ParamTypes = []string{"string", "int", "int64", "uint64", "bool"}
+
+// Methods which can scope nested routes, as closures in Medeina.
+var scopeMethods = map[string]func(m *medeina.Medeina, handle medeina.Handle){
+	medeina.GET:    (*medeina.Medeina).GET,
+	medeina.POST:   (*medeina.Medeina).POST,
+	medeina.PUT:    (*medeina.Medeina).PUT,
+	medeina.PATCH:  (*medeina.Medeina).PATCH,
+	medeina.DELETE: (*medeina.Medeina).DELETE,
+}
+
+// An entry of a document, keeping its nodes for error positions.
+type entry struct {
+	node    *yaml.Node
+	on      *yaml.Node
+	is      *yaml.Node
+	handler *yaml.Node
+	name    *yaml.Node
+	params  *yaml.Node
+	methods []*yaml.Node
+	use     []*yaml.Node
+	routes  []*entry
+}
+
+type loader struct {
+	file     string
+	registry *Registry
+	// Stubs resolve every name to a no-op, when only routes matter.
+	stubs     bool
+	endpoints []Endpoint
+	// Nodes causing the diagnostics of the tree, when linting.
+	positions []*yaml.Node
+	errors    Errors
+}
+
+func (l *loader) errorf(node *yaml.Node, format string, args ...interface{}) {
+	l.errors = append(l.errors, &Error{
+		File:    l.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Reads a YAML or JSON file into a new tree.
+func LoadFile(path string, registry *Registry, options ...medeina.Option) (*medeina.Medeina, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data, path, registry, options...)
+}
+
+// Reads a YAML or JSON document into a new tree. The file name is only
+// used in errors.
+func Load(data []byte, file string, registry *Registry, options ...medeina.Option) (*medeina.Medeina, error) {
+	m := medeina.NewMedeina(options...)
+	if err := Apply(m, data, file, registry); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Adds the routes of a YAML or JSON document to an existing tree, under
+// its current context. Documents with unknown names or methods don't
+// register anything, while routes conflicting with others are reported
+// after registering the rest. Errors in the document are of type Errors.
+func Apply(m *medeina.Medeina, data []byte, file string, registry *Registry) error {
+	if registry == nil {
+		return errors.New("medeina: nil registry")
+	}
+	return (&loader{file: file, registry: registry}).apply(m, data)
+}
+
+// Returns the routes of a YAML or JSON document with their names and param
+// types. Handlers and middlewares aren't resolved, so no registry is needed,
+// but the paths are checked as when loading the document.
+func Endpoints(data []byte, file string) ([]Endpoint, error) {
+	l := &loader{file: file, stubs: true}
+	if err := l.apply(medeina.NewMedeina(), data); err != nil {
+		return nil, err
+	}
+	return l.endpoints, nil
+}
+
+// Diagnostic is a diagnostic of the tree built by a document, with the
+// position of the route or scope causing it.
+type Diagnostic struct {
+	medeina.Diagnostic
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Diagnostic)
+}
+
+// Checks the tree built by a document, as medeina.WithLint does, without
+// resolving its handlers and middlewares. Problems of the document itself,
+// like unknown keys, are returned as Errors.
+func Lint(data []byte, file string) ([]Diagnostic, error) {
+	l := &loader{file: file, stubs: true}
+	m := medeina.NewMedeina(medeina.WithLint())
+	if err := l.apply(m, data); err != nil {
+		return nil, err
+	}
+	var diagnostics []Diagnostic
+	for i, d := range m.Diagnostics() {
+		node := l.positions[i]
+		diagnostics = append(diagnostics, Diagnostic{Diagnostic: d, File: file, Line: node.Line, Column: node.Column})
+	}
+	return diagnostics, nil
+}
+
+// Assigns the diagnostics of the tree not placed yet to a node.
+func (l *loader) locate(m *medeina.Medeina, node *yaml.Node) {
+	for len(l.positions) < len(m.Diagnostics()) {
+		l.positions = append(l.positions, node)
+	}
+}
+
+func (l *loader) apply(m *medeina.Medeina, data []byte) error {
+	var document yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&document); err != nil {
+		return Errors{{File: l.file, Line: 1, Column: 1, Message: err.Error()}}
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return Errors{{File: l.file, Line: 1, Column: 1, Message: "empty document"}}
+	}
+	root := document.Content[0]
+	if root.Kind == yaml.MappingNode {
+		routes := l.field(root, "routes")
+		if routes == nil {
+			l.errorf(root, "missing routes")
+			return l.errors
+		}
+		root = routes
+	}
+	entries := l.parseList(root)
+	l.check(entries, false)
+	if len(l.errors) > 0 {
+		return l.errors
+	}
+	l.build(m, entries)
+	if len(l.errors) > 0 {
+		return l.errors
+	}
+	return nil
+}
+
+// Returns the value of a key in a mapping, reporting any other key.
+func (l *loader) field(mapping *yaml.Node, key string) *yaml.Node {
+	var value *yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value = mapping.Content[i+1]
+		} else {
+			l.errorf(mapping.Content[i], "unknown key %q", mapping.Content[i].Value)
+		}
+	}
+	return value
+}
+
+func (l *loader) parseList(node *yaml.Node) []*entry {
+	if node.Kind != yaml.SequenceNode {
+		l.errorf(node, "expected a list of routes")
+		return nil
+	}
+	entries := make([]*entry, 0, len(node.Content))
+	for _, item := range node.Content {
+		if e := l.parseEntry(item); e != nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Returns the scalars of a node holding a scalar or a list of them.
+func (l *loader) scalars(node *yaml.Node) []*yaml.Node {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []*yaml.Node{node}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				l.errorf(item, "expected a name")
+				return nil
+			}
+		}
+		return node.Content
+	}
+	l.errorf(node, "expected a name or a list of names")
+	return nil
+}
+
+func (l *loader) parseEntry(node *yaml.Node) *entry {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "expected a route")
+		return nil
+	}
+	e := &entry{node: node}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "on":
+			e.on = value
+		case "is":
+			e.is = value
+		case "handler":
+			e.handler = value
+		case "name":
+			e.name = value
+		case "params":
+			e.params = value
+		case "methods":
+			e.methods = l.scalars(value)
+		case "use":
+			e.use = l.scalars(value)
+		case "routes":
+			e.routes = l.parseList(value)
+		default:
+			l.errorf(key, "unknown key %q", key.Value)
+		}
+	}
+	return e
+}
+
+// Checks names and the shape of entries. inMethod tells if an enclosing
+// entry set a method scope.
+func (l *loader) check(entries []*entry, inMethod bool) {
+	for _, e := range entries {
+		for _, name := range e.use {
+			if l.stubs {
+				continue
+			}
+			_, isMiddleware := l.registry.Middlewares[name.Value]
+			_, isScope := l.registry.Scopes[name.Value]
+			if !isMiddleware && !isScope {
+				l.errorf(name, "unknown middleware %q", name.Value)
+			}
+		}
+		for _, method := range e.methods {
+			if e.on != nil {
+				if _, ok := scopeMethods[method.Value]; !ok {
+					l.errorf(method, "unknown method scope %q", method.Value)
+				}
+			} else if !validMethod(method.Value) {
+				l.errorf(method, "unknown method %q", method.Value)
+			}
+		}
+		switch {
+		case e.on != nil && e.is != nil:
+			l.errorf(e.node, "a route can't have both on and is")
+		case e.on != nil:
+			if e.handler != nil {
+				l.errorf(e.handler, "handlers go in is routes, not in on")
+			}
+			l.check(e.routes, inMethod || len(e.methods) > 0)
+		case e.is != nil:
+			if e.routes != nil {
+				l.errorf(e.node, "is routes can't have nested routes")
+			}
+			if e.handler == nil {
+				l.errorf(e.node, "missing handler")
+			} else if _, _, ok := l.handle(e.handler.Value); !ok {
+				l.errorf(e.handler, "unknown handler %q", e.handler.Value)
+			}
+			l.checkMetadata(e)
+			if !inMethod && len(e.methods) == 0 {
+				l.errorf(e.is, "route %q is outside any method scope", e.is.Value)
+			}
+		default:
+			l.errorf(e.node, "a route needs on or is")
+		}
+		if e.is == nil && (e.name != nil || e.params != nil) {
+			l.errorf(e.node, "only is routes can have a name or params")
+		}
+	}
+}
+
+// Checks the name and param types of an is route.
+func (l *loader) checkMetadata(e *entry) {
+	if e.name != nil && !token.IsIdentifier(e.name.Value) {
+		l.errorf(e.name, "invalid name %q", e.name.Value)
+	}
+	if e.params == nil {
+		return
+	}
+	if e.params.Kind != yaml.MappingNode {
+		l.errorf(e.params, "expected params with their types")
+		return
+	}
+	for i := 0; i+1 < len(e.params.Content); i += 2 {
+		if kind := e.params.Content[i+1]; !validParamType(kind.Value) {
+			l.errorf(kind, "unknown param type %q", kind.Value)
+		}
+	}
+}
+
+func validParamType(kind string) bool {
+	for _, valid := range ParamTypes {
+		if kind == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func validMethod(method string) bool {
+	if _, ok := scopeMethods[method]; ok {
+		return true
+	}
+	return method == medeina.HEAD || method == medeina.OPTIONS
+}
+
+// Resolves a handler name.
+func (l *loader) handle(name string) (httprouter.Handle, http.Handler, bool) {
+	if l.stubs {
+		return func(http.ResponseWriter, *http.Request, httprouter.Params) {}, nil, true
+	}
+	if handle, ok := l.registry.Handles[name]; ok {
+		return handle, nil, true
+	}
+	if handler, ok := l.registry.Handlers[name]; ok {
+		return nil, handler, true
+	}
+	return nil, nil, false
+}
+
+func (l *loader) build(m *medeina.Medeina, entries []*entry) {
+	for _, e := range entries {
+		l.use(m, e.use, func() {
+			if e.on != nil {
+				m.On(e.on.Value, func() {
+					l.locate(m, e.on)
+					if len(e.methods) == 0 {
+						l.build(m, e.routes)
+						return
+					}
+					for _, method := range e.methods {
+						scopeMethods[method.Value](m, func() {
+							l.build(m, e.routes)
+						})
+					}
+				})
+				return
+			}
+			l.register(m, e)
+		})
+	}
+}
+
+// Applies the named middlewares and scopes, outermost first.
+func (l *loader) use(m *medeina.Medeina, names []*yaml.Node, handle medeina.Handle) {
+	if len(names) == 0 || l.stubs {
+		handle()
+		return
+	}
+	inner := func() {
+		l.use(m, names[1:], handle)
+	}
+	if middleware, ok := l.registry.Middlewares[names[0].Value]; ok {
+		m.Use(middleware, inner)
+		return
+	}
+	l.registry.Scopes[names[0].Value](m, inner)
+}
+
+// Registers an is route, turning conflicts into errors.
+func (l *loader) register(m *medeina.Medeina, e *entry) {
+	// Only routes refused by the tree are reported, other panics are
+	// raised as they are.
+	defer func() {
+		if p := recover(); p != nil {
+			err, ok := p.(*medeina.RouteError)
+			if !ok {
+				panic(p)
+			}
+			l.errorf(e.is, "route %q conflicts: %v", e.is.Value, err)
+		}
+	}()
+	var methods []medeina.Method
+	for _, method := range e.methods {
+		methods = append(methods, medeina.Method(method.Value))
+	}
+	handle, handler, _ := l.handle(e.handler.Value)
+	registered := len(m.Routes())
+	if handler != nil {
+		m.Handler(e.is.Value, handler, methods...)
+	} else {
+		m.Is(e.is.Value, handle, methods...)
+	}
+	l.locate(m, e.is)
+	if l.stubs {
+		l.describe(e, m.Routes()[registered:])
+	}
+}
+
+// Keeps the routes registered by an is route as endpoints.
+func (l *loader) describe(e *entry, routes []medeina.Route) {
+	params := map[string]string{}
+	if e.params != nil {
+		for i := 0; i+1 < len(e.params.Content); i += 2 {
+			name := e.params.Content[i]
+			if len(routes) > 0 && !strings.Contains(routes[0].Path+"/", ":"+name.Value+"/") && !strings.HasSuffix(routes[0].Path, "*"+name.Value) {
+				l.errorf(name, "unknown param %q in %s", name.Value, routes[0].Path)
+			}
+			params[name.Value] = e.params.Content[i+1].Value
+		}
+	}
+	for _, route := range routes {
+		endpoint := Endpoint{Route: route, Params: params}
+		if e.name != nil {
+			endpoint.Name = e.name.Value
+		}
+		l.endpoints = append(l.endpoints, endpoint)
+	}
+}
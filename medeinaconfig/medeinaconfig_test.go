@@ -0,0 +1,208 @@
+package medeinaconfig
+
+import (
+	"fmt"
+	"github.com/imdario/medeina"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getRepo(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	fmt.Fprintf(w, "repo %s", ps.ByName("repo"))
+}
+
+func topics(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	fmt.Fprintf(w, "%s topics", r.Method)
+}
+
+var registry = &Registry{
+	Handles: map[string]httprouter.Handle{
+		"getRepo": getRepo,
+		"topics":  topics,
+	},
+	Handlers: map[string]http.Handler{
+		"health": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}),
+	},
+	Scopes: map[string]Scope{
+		"cached": func(m *medeina.Medeina, handle medeina.Handle) {
+			m.Cache(&medeina.Cache{TTL: time.Minute}, handle)
+		},
+	},
+}
+
+const document = `
+routes:
+  - is: health
+    methods: [GET, HEAD]
+    handler: health
+  - on: repos/:owner/:repo
+    routes:
+      - on: ""
+        methods: GET
+        use: cached
+        routes:
+          - is: ""
+            handler: getRepo
+      - is: topics
+        methods: [GET, PUT]
+        handler: topics
+`
+
+func TestLoad(t *testing.T) {
+	m, err := Load([]byte(document), "routes.yaml", registry)
+	if err != nil {
+		t.Fatalf("Expected the document to load, found %v", err)
+	}
+	mr := medeina.NewMedeina()
+	mr.Handler("health", registry.Handlers["health"], medeina.GET, medeina.HEAD)
+	mr.On("repos/:owner/:repo", func() {
+		mr.On("", func() {
+			mr.GET(func() {
+				mr.Cache(&medeina.Cache{TTL: time.Minute}, func() {
+					mr.Is("", getRepo)
+				})
+			})
+		})
+		mr.Is("topics", topics, medeina.GET, medeina.PUT)
+	})
+	if !reflect.DeepEqual(m.Routes(), mr.Routes()) {
+		t.Errorf("Expected the routes of the closures %v, found %v", mr.Routes(), m.Routes())
+	}
+	r, _ := http.NewRequest("PUT", "/repos/imdario/medeina/topics", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "PUT topics" {
+		t.Errorf("Expected the topics handler, found %q", w.Body.String())
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	m, err := Load([]byte(`[
+		{"on": "repos/:owner/:repo", "methods": ["GET"], "routes": [
+			{"is": "", "handler": "getRepo"}
+		]}
+	]`), "routes.json", registry)
+	if err != nil {
+		t.Fatalf("Expected the document to load, found %v", err)
+	}
+	r, _ := http.NewRequest("GET", "/repos/imdario/medeina", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if w.Body.String() != "repo medeina" {
+		t.Errorf("Expected the getRepo handler, found %q", w.Body.String())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		document string
+		expected []string
+	}{
+		{`
+routes:
+  - on: repos
+    methods: GET
+    use: [missing]
+    routes:
+      - is: ""
+        handler: nope
+      - is: x
+        methods: [GIT]
+        handler: topics
+  - is: orphan
+    handler: topics
+`, []string{
+			`routes.yaml:5:11: unknown middleware "missing"`,
+			`routes.yaml:8:18: unknown handler "nope"`,
+			`routes.yaml:10:19: unknown method "GIT"`,
+			`routes.yaml:12:9: route "orphan" is outside any method scope`,
+		}},
+		{`
+- on: repos
+  methods: GET
+  routes:
+    - is: :owner
+      handler: getRepo
+    - is: :name
+      handler: getRepo
+`, []string{
+			`routes.yaml:7:11: route ":name" conflicts: `,
+		}},
+	}
+	for _, test := range tests {
+		_, err := Load([]byte(test.document), "routes.yaml", registry)
+		errs, ok := err.(Errors)
+		if !ok || len(errs) != len(test.expected) {
+			t.Errorf("Expected %d errors, found %v", len(test.expected), err)
+			continue
+		}
+		for i, expected := range test.expected {
+			if !strings.HasPrefix(errs[i].Error(), expected) {
+				t.Errorf("Expected error %q, found %q", expected, errs[i].Error())
+			}
+		}
+	}
+}
+
+func TestLoadNilRegistry(t *testing.T) {
+	if _, err := Load([]byte(document), "routes.yaml", nil); err == nil {
+		t.Error("Expected an error for a nil registry")
+	}
+}
+
+func TestLoadPanics(t *testing.T) {
+	failing := &Registry{
+		Handles: registry.Handles,
+		Middlewares: map[string]medeina.Middleware{
+			"broken": func(route *medeina.Route, handle httprouter.Handle) httprouter.Handle {
+				panic("broken middleware")
+			},
+		},
+	}
+	defer func() {
+		if p := recover(); p != "broken middleware" {
+			t.Errorf("Expected the panic of the middleware, found %v", p)
+		}
+	}()
+	Load([]byte(`
+- on: repos
+  methods: GET
+  use: broken
+  routes:
+    - is: ""
+      handler: getRepo
+`), "routes.yaml", failing)
+}
+
+func TestLint(t *testing.T) {
+	diagnostics, err := Lint([]byte(`
+- on: repos/:owner
+  methods: [GET]
+  routes:
+    - is: :repo
+      handler: getRepo
+    - on: ""
+      routes:
+        - is: :name
+          handler: getRepo
+`), "routes.yaml")
+	expected := []string{
+		`routes.yaml:7:11: warning: empty-on: /repos/:owner (on "repos/:owner" -> ""): empty subpath, use Is("") to route the scope itself`,
+		`routes.yaml:9:15: error: conflict: GET /repos/:owner//:name (on "repos/:owner" -> ""): empty segment conflicts with :repo of GET /repos/:owner/:repo (on "repos/:owner")`,
+	}
+	if err != nil || len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, found %v (%v)", len(expected), diagnostics, err)
+	}
+	for i, d := range diagnostics {
+		if d.String() != expected[i] {
+			t.Errorf("Expected %s, found %s", expected[i], d)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeinagen
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/imdario/medeina"
+	"go/format"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// An operation of a generated client.
+type operation struct {
+	Name    string
+	Method  string
+	Pattern string
+	Params  []*param
+	// Expression building the path.
+	Path string
+	// Types of the request and response bodies, if any.
+	Request  string
+	Response string
+	// Tells if the response is returned as a pointer to it.
+	Pointer bool
+}
+
+// Imports of a generated client, by path, with the name they're used by.
+type clientImports struct {
+	names map[string]string
+	taken map[string]bool
+	// Names which generated code uses for packages it may import later,
+	// by name.
+	reserved map[string]string
+}
+
+func (ci *clientImports) use(importPath string) string {
+	if name, ok := ci.names[importPath]; ok {
+		return name
+	}
+	base := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, packageName(importPath))
+	name := base
+	for i := 2; ci.taken[name] || (ci.reserved[name] != "" && ci.reserved[name] != importPath); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	ci.names[importPath] = name
+	ci.taken[name] = true
+	return name
+}
+
+// Returns the last element of an import path, usually its package name.
+func packageName(importPath string) string {
+	return importPath[strings.LastIndex(importPath, "/")+1:]
+}
+
+// Returns the specs of the imports, as written in the import block.
+func (ci *clientImports) specs() []string {
+	var specs []string
+	for importPath, name := range ci.names {
+		if name == packageName(importPath) {
+			specs = append(specs, fmt.Sprintf("%q", importPath))
+		} else {
+			specs = append(specs, fmt.Sprintf("%s %q", name, importPath))
+		}
+	}
+	return specs
+}
+
+// Returns how generated code refers to a type, importing its package.
+func (ci *clientImports) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		switch {
+		case strings.Contains(t.Name(), "["):
+			return "", fmt.Errorf("medeina: generic type %s isn't supported", t)
+		case t.PkgPath() == "":
+			return t.Name(), nil
+		case t.PkgPath() == "main" || strings.HasSuffix(t.PkgPath(), "_test"):
+			return "", fmt.Errorf("medeina: type %s can't be imported", t)
+		}
+		return ci.use(t.PkgPath()) + "." + t.Name(), nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		elem, err := ci.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		}
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), nil
+	case reflect.Map:
+		key, err := ci.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := ci.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + elem, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+	return "", fmt.Errorf("medeina: unnamed type %s isn't supported", t)
+}
+
+// Generates a Go client package for the routes of a tree, with a method
+// per route taking its path params and the bodies given by Describe.
+// Bodies are encoded as JSON, and responses other than 2xx are returned as
+// errors. WebSocket, Server-Sent Events, HEAD and OPTIONS routes are
+// skipped.
+func GenerateClient(pkg string, routes []medeina.Route) ([]byte, error) {
+	// Packages can't take the names of the variables in generated methods.
+	ci := &clientImports{names: map[string]string{}, taken: map[string]bool{
+		"body": true, "c": true, "ctx": true, "err": true, "result": true,
+	}, reserved: map[string]string{"strconv": "strconv", "url": "net/url"}}
+	for _, std := range []string{"bytes", "context", "encoding/json", "fmt", "io", "net/http", "strings"} {
+		ci.use(std)
+	}
+	var operations []*operation
+	names := map[string]string{}
+	for _, route := range routes {
+		if route.WebSocket || route.SSE || route.Method == medeina.HEAD || route.Method == medeina.OPTIONS {
+			continue
+		}
+		op, err := newOperation(ci, route)
+		if err != nil {
+			return nil, err
+		}
+		key := route.Method + " " + route.Path
+		if other, ok := names[op.Name]; ok {
+			return nil, fmt.Errorf("medeina: %s and %s are both named %s, give them names with Describe", other, key, op.Name)
+		}
+		names[op.Name] = key
+		operations = append(operations, op)
+	}
+	var b bytes.Buffer
+	err := clientTemplate.Execute(&b, struct {
+		Package    string
+		Imports    []string
+		Operations []*operation
+	}{pkg, ci.specs(), operations})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(b.Bytes())
+}
+
+func newOperation(ci *clientImports, route medeina.Route) (*operation, error) {
+	schema := route.Schema
+	if schema == nil {
+		schema = &medeina.Schema{}
+	}
+	op := &operation{
+		Name:    schema.Name,
+		Method:  route.Method,
+		Pattern: route.Path,
+	}
+	if op.Name == "" {
+		op.Name = identifier(strings.ToLower(route.Method), true) + deriveName(route.Path)
+	}
+	types := map[string]string{}
+	for name, kind := range schema.Params {
+		types[name] = kind
+	}
+	params, err := pathParams(route.Path, types)
+	if err != nil {
+		return nil, err
+	}
+	op.Params = params
+	op.Path = buildExpression(&path{Pattern: route.Path, Params: params})
+	for _, param := range params {
+		switch {
+		case param.Type != "string":
+			ci.use("strconv")
+		default:
+			ci.use("net/url")
+		}
+	}
+	if schema.Request != nil {
+		t := reflect.TypeOf(schema.Request)
+		if t.Kind() == reflect.Struct {
+			t = reflect.PtrTo(t)
+		}
+		if op.Request, err = ci.typeName(t); err != nil {
+			return nil, err
+		}
+	}
+	if schema.Response != nil {
+		t := reflect.TypeOf(schema.Response)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		op.Pointer = t.Kind() == reflect.Struct
+		if op.Response, err = ci.typeName(t); err != nil {
+			return nil, err
+		}
+	}
+	return op, nil
+}
+
+var clientTemplate = template.Must(template.New("client").Funcs(template.FuncMap{
+	"arguments": arguments,
+}).Parse(`// Code generated by medeina-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	{{.}}
+{{end}})
+
+// Client calls the API at BaseURL.
+type Client struct {
+	// URL the paths of routes are appended to, like https://api.example.com.
+	BaseURL string
+	// Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a client of the API at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL}
+}
+
+// Error is returned for responses with a status other than 2xx.
+type Error struct {
+	StatusCode int
+	// Taken from the message or error field of JSON bodies, or the whole
+	// body otherwise.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Reads the error of a response.
+func newError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	e := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var body struct {
+		Message string ` + "`json:\"message\"`" + `
+		Error   string ` + "`json:\"error\"`" + `
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Message != "" {
+			e.Message = body.Message
+		} else if body.Error != "" {
+			e.Message = body.Error
+		}
+	}
+	return e
+}
+
+// Sends a request, encoding body as JSON if any, and decodes the response
+// into result if any.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newError(resp)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+{{range .Operations}}
+// {{.Name}} calls {{.Method}} {{.Pattern}}.
+func (c *Client) {{.Name}}(ctx context.Context{{if .Params}}, {{arguments .Params}}{{end}}{{if .Request}}, body {{.Request}}{{end}}) {{if .Response}}({{if .Pointer}}*{{end}}{{.Response}}, error){{else}}error{{end}} {
+{{- if .Response}}
+	var result {{.Response}}
+	err := c.do(ctx, "{{.Method}}", {{.Path}}, {{if .Request}}body{{else}}nil{{end}}, &result)
+{{- if .Pointer}}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+{{- else}}
+	return result, err
+{{- end}}
+{{- else}}
+	return c.do(ctx, "{{.Method}}", {{.Path}}, {{if .Request}}body{{else}}nil{{end}}, nil)
+{{- end}}
+}
+{{end}}`))
@@ -0,0 +1,90 @@
+package medeinagen
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/imdario/medeina"
+	"github.com/imdario/medeina/medeinagen/internal/example"
+	"github.com/imdario/medeina/medeinagen/internal/example/client"
+	"github.com/imdario/medeina/medeinagen/internal/example/url"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateClient(t *testing.T) {
+	code, err := GenerateClient("client", example.Tree().Routes())
+	if err != nil {
+		t.Fatalf("Expected a client, found %v", err)
+	}
+	committed, err := os.ReadFile("internal/example/client/client_gen.go")
+	if err != nil || !bytes.Equal(code, committed) {
+		t.Errorf("Expected the example client to be up to date, run go generate ./internal/example/client (%v)", err)
+	}
+	mr := medeina.NewMedeina()
+	mr.GET(func() {
+		mr.Is("pulls", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+		mr.Is("pulls/:number/close", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+		mr.Describe(&medeina.Schema{Name: "GetPulls"}, func() {
+			mr.Is("all/pulls", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+		})
+	})
+	if _, err := GenerateClient("client", mr.Routes()); err == nil {
+		t.Errorf("Expected clashing operation names to fail")
+	}
+}
+
+func TestGenerateClientImports(t *testing.T) {
+	mr := medeina.NewMedeina()
+	mr.GET(func() {
+		mr.Describe(&medeina.Schema{Response: url.Link{}}, func() {
+			mr.Is("links/:id", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+		})
+	})
+	code, err := GenerateClient("client", mr.Routes())
+	if err != nil {
+		t.Fatalf("Expected a client, found %v", err)
+	}
+	for _, expected := range []string{
+		`url2 "github.com/imdario/medeina/medeinagen/internal/example/url"`,
+		`"net/url"`,
+		`url.PathEscape(id)`,
+		`*url2.Link`,
+	} {
+		if !bytes.Contains(code, []byte(expected)) {
+			t.Errorf("Expected %s in the client, found\n%s", expected, code)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(example.Tree())
+	defer server.Close()
+	c := client.New(server.URL)
+	ctx := context.Background()
+	pull, err := c.CreatePull(ctx, "imdario", "medeina", &example.NewPull{Title: "Generated clients"})
+	expected := &example.Pull{Number: 1, Title: "Generated clients", State: "open"}
+	if err != nil || !reflect.DeepEqual(pull, expected) {
+		t.Errorf("Expected %v, found %v (%v)", expected, pull, err)
+	}
+	if err := c.ClosePull(ctx, "imdario", "medeina", 1); err != nil {
+		t.Errorf("Expected the pull closed, found %v", err)
+	}
+	pulls, err := c.GetRepoPulls(ctx, "imdario", "medeina")
+	if err != nil || len(pulls) != 1 || pulls[0].State != "closed" {
+		t.Errorf("Expected a closed pull, found %v (%v)", pulls, err)
+	}
+	_, err = c.GetRepoPull(ctx, "imdario", "medeina", 2)
+	var clientErr *client.Error
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusNotFound || clientErr.Message != "pull not found" {
+		t.Errorf("Expected a decoded 404 error, found %v", err)
+	}
+	_, err = c.CreatePull(ctx, "imdario", "medeina", &example.NewPull{})
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a 422 error, found %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+// Package client is the generated client of the example tree.
+package client
+
+//go:generate go run gen.go
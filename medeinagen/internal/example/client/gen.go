@@ -0,0 +1,23 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+//go:build ignore
+
+// Generates the client of the example tree.
+package main
+
+import (
+	"github.com/imdario/medeina/medeinagen"
+	"github.com/imdario/medeina/medeinagen/internal/example"
+	"log"
+	"os"
+)
+
+func main() {
+	code, err := medeinagen.GenerateClient("client", example.Tree().Routes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("client_gen.go", code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+// Package example serves a small described API, used to test generated
+// clients end to end.
+package example
+
+import (
+	"encoding/json"
+	"github.com/imdario/medeina"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Pull is a pull request.
+type Pull struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+}
+
+// NewPull is the body to open a pull request.
+type NewPull struct {
+	Title string `json:"title"`
+}
+
+type store struct {
+	mutex sync.Mutex
+	pulls map[string][]*Pull
+}
+
+// Returns a tree serving the pull requests of any repository from memory.
+func Tree() *medeina.Medeina {
+	s := &store{pulls: map[string][]*Pull{}}
+	m := medeina.NewMedeina()
+	number := map[string]string{"number": "int"}
+	m.On("repos/:owner/:repo/pulls", func() {
+		m.Describe(&medeina.Schema{Response: []Pull{}}, func() {
+			m.Is("", s.list, medeina.GET)
+		})
+		m.Describe(&medeina.Schema{Name: "CreatePull", Request: NewPull{}, Response: Pull{}}, func() {
+			m.Is("", s.create, medeina.POST)
+		})
+		m.Describe(&medeina.Schema{Response: Pull{}, Params: number}, func() {
+			m.Is(":number", s.get, medeina.GET)
+		})
+		m.Describe(&medeina.Schema{Name: "ClosePull", Params: number}, func() {
+			m.Is(":number", s.close, medeina.DELETE)
+		})
+	})
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *store) list(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pulls := []Pull{}
+	for _, pull := range s.pulls[ps.ByName("owner")+"/"+ps.ByName("repo")] {
+		pulls = append(pulls, *pull)
+	}
+	writeJSON(w, http.StatusOK, pulls)
+}
+
+func (s *store) create(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var body NewPull
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Title == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "missing title"})
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	repo := ps.ByName("owner") + "/" + ps.ByName("repo")
+	pull := &Pull{Number: len(s.pulls[repo]) + 1, Title: body.Title, State: "open"}
+	s.pulls[repo] = append(s.pulls[repo], pull)
+	writeJSON(w, http.StatusCreated, pull)
+}
+
+// Returns the pull request of a route, or answers 404.
+func (s *store) find(w http.ResponseWriter, ps httprouter.Params) *Pull {
+	pulls := s.pulls[ps.ByName("owner")+"/"+ps.ByName("repo")]
+	number, err := strconv.Atoi(ps.ByName("number"))
+	if err != nil || number < 1 || number > len(pulls) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "pull not found"})
+		return nil
+	}
+	return pulls[number-1]
+}
+
+func (s *store) get(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if pull := s.find(w, ps); pull != nil {
+		writeJSON(w, http.StatusOK, pull)
+	}
+}
+
+func (s *store) close(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if pull := s.find(w, ps); pull != nil {
+		pull.State = "closed"
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
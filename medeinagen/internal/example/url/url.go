@@ -0,0 +1,10 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+// Package url shares its name with net/url, used by generated clients, to
+// test how their imports are named.
+package url
+
+// Link is a link to a resource.
+type Link struct {
+	Href string `json:"href"`
+}
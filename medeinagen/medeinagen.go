@@ -0,0 +1,279 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+/*
+Package medeinagen generates Go code from the routes of Medeina trees, so
+typos in paths and param names become compile errors. For every path it
+emits a constant with the pattern, a typed URL builder and a struct with
+its params:
+
+	const RepoPull = "/repos/:owner/:repo/pulls/:number" // GET
+
+	func RepoPullURL(owner, repo string, number int) string
+
+	type RepoPullParams struct {
+		Owner  string
+		Repo   string
+		Number int
+	}
+
+Routes are read from declarative files, as loaded by medeinaconfig, or from
+the JSON written by WriteRoutes for trees built in Go. Names are derived
+from static segments, singular when followed by a param, unless given.
+Params are strings unless given another type in ParamTypes.
+
+GenerateClient emits a Go client for the routes of a tree instead, with a
+method per route. Request and response bodies, operation names and param
+types are taken from the schemas given by Describe:
+
+	func (c *Client) GetRepoPull(ctx context.Context, owner, repo string, number int) (*api.Pull, error)
+
+The medeina-gen command wraps Generate for go generate. Clients need the
+types of the tree, so they're generated by a small program importing it.
+*/
+package medeinagen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imdario/medeina"
+	"github.com/imdario/medeina/medeinaconfig"
+	"go/token"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Route is a route to generate code for.
+type Route struct {
+	// Go identifier naming the route. Derived from the path if empty.
+	Name   string `json:"name,omitempty"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Go types of the path params, by param name. Missing params are strings.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Returns the routes of a tree, as returned by its Routes method.
+func FromTree(routes []medeina.Route) []Route {
+	generated := make([]Route, len(routes))
+	for i, route := range routes {
+		generated[i] = Route{Method: route.Method, Path: route.Path}
+	}
+	return generated
+}
+
+// Returns the routes of a declarative document, with the names and param
+// types given in it.
+func FromConfig(data []byte, file string) ([]Route, error) {
+	endpoints, err := medeinaconfig.Endpoints(data, file)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]Route, len(endpoints))
+	for i, endpoint := range endpoints {
+		routes[i] = Route{
+			Name:   endpoint.Name,
+			Method: endpoint.Method,
+			Path:   endpoint.Path,
+			Params: endpoint.Params,
+		}
+	}
+	return routes, nil
+}
+
+// Writes the routes of a tree as JSON, to be read by ReadRoutes or the
+// medeina-gen command. Names and param types can be added to the output.
+func WriteRoutes(w io.Writer, routes []medeina.Route) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(FromTree(routes))
+}
+
+// Reads routes written by WriteRoutes.
+func ReadRoutes(r io.Reader) ([]Route, error) {
+	var routes []Route
+	if err := json.NewDecoder(r).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("medeina: invalid routes: %v", err)
+	}
+	return routes, nil
+}
+
+// A path with its routes, as generated.
+type path struct {
+	Name    string
+	Pattern string
+	Methods []string
+	Params  []*param
+}
+
+// A path param, as generated.
+type param struct {
+	// Name in the pattern.
+	Name string
+	// Struct field and function argument.
+	Field string
+	Arg   string
+	Type  string
+	// Tells if it is a catch-all param.
+	CatchAll bool
+}
+
+// Groups routes by path, checking names and param types.
+func groupPaths(routes []Route) ([]*path, error) {
+	var paths []*path
+	byPattern := map[string]*path{}
+	types := map[string]map[string]string{}
+	for _, route := range routes {
+		p, ok := byPattern[route.Path]
+		if !ok {
+			p = &path{Pattern: route.Path}
+			byPattern[route.Path] = p
+			types[route.Path] = map[string]string{}
+			paths = append(paths, p)
+		}
+		p.Methods = append(p.Methods, route.Method)
+		if route.Name != "" {
+			if p.Name != "" && p.Name != route.Name {
+				return nil, fmt.Errorf("medeina: %s is named both %s and %s", route.Path, p.Name, route.Name)
+			}
+			p.Name = route.Name
+		}
+		for name, kind := range route.Params {
+			if previous, ok := types[route.Path][name]; ok && previous != kind {
+				return nil, fmt.Errorf("medeina: param %s of %s is both %s and %s", name, route.Path, previous, kind)
+			}
+			types[route.Path][name] = kind
+		}
+	}
+	names := map[string]string{}
+	for _, p := range paths {
+		if p.Name == "" {
+			p.Name = deriveName(p.Pattern)
+		}
+		if !token.IsIdentifier(p.Name) || !token.IsExported(p.Name) {
+			return nil, fmt.Errorf("medeina: invalid name %q for %s", p.Name, p.Pattern)
+		}
+		if other, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("medeina: %s and %s are both named %s, give them names", other, p.Pattern, p.Name)
+		}
+		names[p.Name] = p.Pattern
+		params, err := pathParams(p.Pattern, types[p.Pattern])
+		if err != nil {
+			return nil, err
+		}
+		p.Params = params
+	}
+	return paths, nil
+}
+
+// Returns the params of a pattern, in order.
+func pathParams(pattern string, types map[string]string) ([]*param, error) {
+	var params []*param
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		p := &param{
+			Name:     segment[1:],
+			Field:    identifier(segment[1:], true),
+			Arg:      identifier(segment[1:], false),
+			Type:     "string",
+			CatchAll: segment[0] == '*',
+		}
+		if kind, ok := types[p.Name]; ok {
+			p.Type = kind
+			delete(types, p.Name)
+		}
+		if !validType(p.Type) || (p.CatchAll && p.Type != "string") {
+			return nil, fmt.Errorf("medeina: unsupported type %s for param %s of %s", p.Type, p.Name, pattern)
+		}
+		params = append(params, p)
+	}
+	for name := range types {
+		return nil, fmt.Errorf("medeina: unknown param %s in %s", name, pattern)
+	}
+	return params, nil
+}
+
+func validType(kind string) bool {
+	for _, valid := range medeinaconfig.ParamTypes {
+		if kind == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Derives a name from the static segments of a pattern, e.g. RepoPull for
+// /repos/:owner/:repo/pulls/:number.
+func deriveName(pattern string) string {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	var name strings.Builder
+	for i, segment := range segments {
+		if segment == "" || segment[0] == ':' || segment[0] == '*' {
+			continue
+		}
+		word := identifier(segment, true)
+		if i+1 < len(segments) && segments[i+1] != "" && segments[i+1][0] == ':' {
+			word = singular(word)
+		}
+		name.WriteString(word)
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "Root" + name.String()
+	}
+	return name.String()
+}
+
+func singular(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "tuses"), strings.HasSuffix(word, "xes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"):
+		return word
+	}
+	return strings.TrimSuffix(word, "s")
+}
+
+// Initialisms kept upper case in identifiers.
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "sha": true, "uri": true, "url": true, "uuid": true,
+}
+
+// Identifiers which generated code uses, and params can't take.
+var reserved = map[string]bool{
+	"body": true, "bytes": true, "c": true, "context": true, "ctx": true,
+	"err": true, "fmt": true, "http": true, "httprouter": true, "io": true,
+	"json": true, "p": true, "ps": true, "result": true, "strconv": true,
+	"strings": true, "url": true,
+}
+
+// Turns a name like repo_id or repo-id into RepoID, or repoID if not
+// exported.
+func identifier(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for i, word := range words {
+		switch {
+		case i == 0 && !exported:
+			b.WriteString(strings.ToLower(word[:1]) + word[1:])
+		case initialisms[strings.ToLower(word)]:
+			b.WriteString(strings.ToUpper(word))
+		default:
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "param"
+	}
+	if !exported && (token.IsKeyword(id) || reserved[id]) {
+		id += "Param"
+	}
+	return id
+}
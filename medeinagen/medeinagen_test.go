@@ -0,0 +1,114 @@
+package medeinagen
+
+import (
+	"bytes"
+	"github.com/imdario/medeina"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeriveName(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"/":                                 "Root",
+		"/repos/:owner/:repo":               "Repo",
+		"/repos/:owner/:repo/pulls/:number": "RepoPull",
+		"/repos/:owner/:repo/issues":        "RepoIssues",
+		"/repos/:owner/:repo/statuses/:sha": "RepoStatus",
+		"/gists/:id/star":                   "GistStar",
+		"/user/repository-invitations/:id":  "UserRepositoryInvitation",
+		"/static/*filepath":                 "Static",
+		"/2fa":                              "Root2fa",
+	} {
+		if name := deriveName(pattern); name != expected {
+			t.Errorf("Expected %s for %s, found %s", expected, pattern, name)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/repos/:owner/:repo/pulls/:number", Params: map[string]string{"number": "int"}},
+		{Method: "PATCH", Path: "/repos/:owner/:repo/pulls/:number"},
+		{Method: "GET", Path: "/users/:user_id", Name: "User"},
+		{Method: "GET", Path: "/static/*filepath"},
+		{Method: "GET", Path: "/health"},
+	}
+	code, err := Generate("api", routes)
+	if err != nil {
+		t.Fatalf("Expected code, found %v", err)
+	}
+	for _, expected := range []string{
+		"// Code generated by medeina-gen. DO NOT EDIT.\n\npackage api\n",
+		"\"github.com/julienschmidt/httprouter\"\n\t\"net/url\"\n\t\"strconv\"\n\t\"strings\"\n",
+		"RepoPull = \"/repos/:owner/:repo/pulls/:number\" // GET, PATCH\n",
+		"func RepoPullURL(owner, repo string, number int) string {\n\treturn \"/repos/\" + url.PathEscape(owner) + \"/\" + url.PathEscape(repo) + \"/pulls/\" + strconv.Itoa(number)\n}",
+		"type RepoPullParams struct {\n\tOwner  string\n\tRepo   string\n\tNumber int\n}",
+		"\treturn RepoPullURL(p.Owner, p.Repo, p.Number)\n",
+		"\tif p.Number, err = strconv.Atoi(ps.ByName(\"number\")); err != nil {\n",
+		"func UserURL(userID string) string {",
+		"\tp.UserID = ps.ByName(\"user_id\")\n",
+		"return \"/static/\" + strings.TrimPrefix((&url.URL{Path: filepath}).EscapedPath(), \"/\")",
+		"func HealthURL() string {\n\treturn \"/health\"\n}",
+	} {
+		if !bytes.Contains(code, []byte(expected)) {
+			t.Errorf("Expected the code to contain %q, found:\n%s", expected, code)
+		}
+	}
+	if bytes.Contains(code, []byte("HealthParams")) {
+		t.Errorf("Expected no params struct for routes without params")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, routes := range [][]Route{
+		{{Method: "GET", Path: "/repos/:owner"}, {Method: "GET", Path: "/repos/:owner/:repo"}},
+		{{Method: "GET", Path: "/repos/:owner", Params: map[string]string{"repo": "int"}}},
+		{{Method: "GET", Path: "/repos/:owner", Params: map[string]string{"owner": "float64"}}},
+		{{Method: "GET", Path: "/repos/:owner", Name: "repo"}},
+	} {
+		if _, err := Generate("api", routes); err == nil {
+			t.Errorf("Expected %v to fail", routes)
+		}
+	}
+}
+
+func TestRoutesSources(t *testing.T) {
+	mr := medeina.NewMedeina()
+	mr.On("repos/:owner/:repo", func() {
+		mr.Is("", func(http.ResponseWriter, *http.Request, httprouter.Params) {}, medeina.GET)
+	})
+	var b bytes.Buffer
+	if err := WriteRoutes(&b, mr.Routes()); err != nil {
+		t.Fatalf("Expected routes written, found %v", err)
+	}
+	routes, err := ReadRoutes(&b)
+	expected := []Route{{Method: "GET", Path: "/repos/:owner/:repo"}}
+	if err != nil || !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected %v, found %v (%v)", expected, routes, err)
+	}
+	routes, err = FromConfig([]byte(`
+- on: repos/:owner/:repo/pulls
+  methods: GET
+  routes:
+    - is: :number
+      name: Pull
+      params: {number: int}
+      handler: getPull
+`), "routes.yaml")
+	expected = []Route{{Name: "Pull", Method: "GET", Path: "/repos/:owner/:repo/pulls/:number", Params: map[string]string{"number": "int"}}}
+	if err != nil || !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected %v, found %v (%v)", expected, routes, err)
+	}
+	_, err = FromConfig([]byte(`
+- is: repos/:owner
+  methods: GET
+  params: {repo: int}
+  handler: getRepo
+`), "routes.yaml")
+	if err == nil || !strings.Contains(err.Error(), `routes.yaml:4:12: unknown param "repo"`) {
+		t.Errorf("Expected an unknown param error, found %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeinagen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Generates the route constants, URL builders and param structs of the
+// given routes, as a formatted Go file of the given package.
+func Generate(pkg string, routes []Route) ([]byte, error) {
+	paths, err := groupPaths(routes)
+	if err != nil {
+		return nil, err
+	}
+	imports := map[string]bool{}
+	for _, p := range paths {
+		for _, param := range p.Params {
+			imports["github.com/julienschmidt/httprouter"] = true
+			switch {
+			case param.Type != "string":
+				imports["fmt"] = true
+				imports["strconv"] = true
+			case param.CatchAll:
+				imports["net/url"] = true
+				imports["strings"] = true
+			default:
+				imports["net/url"] = true
+			}
+		}
+	}
+	var b bytes.Buffer
+	err = urlsTemplate.Execute(&b, struct {
+		Package string
+		Imports []string
+		Paths   []*path
+	}{pkg, sortedKeys(imports), paths})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(b.Bytes())
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the expression building the URL path of a pattern from the
+// arguments of its params.
+func buildExpression(p *path) string {
+	var parts []string
+	var static strings.Builder
+	i := 0
+	for _, segment := range strings.Split(p.Pattern, "/")[1:] {
+		static.WriteByte('/')
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			static.WriteString(segment)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%q", static.String()))
+		static.Reset()
+		parts = append(parts, formatParam(p.Params[i]))
+		i++
+	}
+	if static.Len() > 0 {
+		parts = append(parts, fmt.Sprintf("%q", static.String()))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// Returns the expression turning a param argument into a path segment.
+func formatParam(param *param) string {
+	switch param.Type {
+	case "int":
+		return "strconv.Itoa(" + param.Arg + ")"
+	case "int64":
+		return "strconv.FormatInt(" + param.Arg + ", 10)"
+	case "uint64":
+		return "strconv.FormatUint(" + param.Arg + ", 10)"
+	case "bool":
+		return "strconv.FormatBool(" + param.Arg + ")"
+	}
+	if param.CatchAll {
+		return "strings.TrimPrefix((&url.URL{Path: " + param.Arg + "}).EscapedPath(), \"/\")"
+	}
+	return "url.PathEscape(" + param.Arg + ")"
+}
+
+// Returns the expression parsing a param from httprouter.Params.
+func parseParam(param *param) string {
+	value := fmt.Sprintf("ps.ByName(%q)", param.Name)
+	switch param.Type {
+	case "int":
+		return "strconv.Atoi(" + value + ")"
+	case "int64":
+		return "strconv.ParseInt(" + value + ", 10, 64)"
+	case "uint64":
+		return "strconv.ParseUint(" + value + ", 10, 64)"
+	case "bool":
+		return "strconv.ParseBool(" + value + ")"
+	}
+	if param.CatchAll {
+		// Catch-all values include their leading slash.
+		return "strings.TrimPrefix(" + value + ", \"/\")"
+	}
+	return value
+}
+
+// Returns the signature of the arguments of a builder, grouping params of
+// the same type as gofmt would leave them.
+func arguments(params []*param) string {
+	var b strings.Builder
+	for i, param := range params {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(param.Arg)
+		if i+1 == len(params) || params[i+1].Type != param.Type {
+			b.WriteString(" " + param.Type)
+		}
+	}
+	return b.String()
+}
+
+// Tells if any param has to be parsed.
+func typed(params []*param) bool {
+	for _, param := range params {
+		if param.Type != "string" {
+			return true
+		}
+	}
+	return false
+}
+
+var urlsTemplate = template.Must(template.New("urls").Funcs(template.FuncMap{
+	"build":     buildExpression,
+	"parse":     parseParam,
+	"arguments": arguments,
+	"typed":     typed,
+	"join":      strings.Join,
+}).Parse(`// Code generated by medeina-gen. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{range .Imports}}	"{{.}}"
+{{end}})
+{{end}}
+// Route patterns, by name.
+const (
+{{range .Paths}}	{{.Name}} = {{printf "%q" .Pattern}} // {{join .Methods ", "}}
+{{end}})
+{{range .Paths}}{{$path := .}}
+// {{.Name}}URL returns the path of {{.Name}}.
+func {{.Name}}URL({{arguments .Params}}) string {
+	return {{build .}}
+}
+{{if .Params}}
+// {{.Name}}Params holds the path params of {{.Name}}.
+type {{.Name}}Params struct {
+{{range .Params}}	{{.Field}} {{.Type}}
+{{end}}}
+
+// URL returns the path of {{.Name}} with these params.
+func (p {{.Name}}Params) URL() string {
+	return {{.Name}}URL({{range $i, $param := .Params}}{{if $i}}, {{end}}p.{{.Field}}{{end}})
+}
+
+// Parse{{.Name}}Params reads the params of {{.Name}} matched by httprouter.
+func Parse{{.Name}}Params(ps httprouter.Params) ({{.Name}}Params, error) {
+	var p {{.Name}}Params
+{{- if typed .Params}}
+	var err error{{end}}
+{{- range .Params}}{{if eq .Type "string"}}
+	p.{{.Field}} = {{parse .}}{{else}}
+	if p.{{.Field}}, err = {{parse .}}; err != nil {
+		return p, fmt.Errorf("invalid param {{.Name}} of {{$path.Name}}: %v", err)
+	}{{end}}{{end}}
+	return p, nil
+}
+{{end}}{{end}}`))
@@ -0,0 +1,330 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+
+/*
+Package medeinatest provides a fluent client to test Medeina trees (or any
+other http.Handler) at request level.
+
+	medeinatest.Client(tree).
+		GET("/repos/imdario/medeina").
+		WithHeader("Accept", "application/json").
+		Expect(t).
+		Status(http.StatusOK).
+		Route("/repos/:owner/:repo").
+		Param("owner", "imdario").
+		JSONPath("$.id", 3)
+
+When the handler is a Medeina tree, failures report which route pattern
+and params matched the request, so they point at the tree definition
+instead of just a status code.
+*/
+package medeinatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/imdario/medeina"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Matcher is implemented by trees able to tell which route handles a
+// request, as *medeina.Medeina does.
+type Matcher interface {
+	Match(method, path string) (*medeina.Route, medeina.Params, bool)
+}
+
+// TestClient sends requests straight to a handler, without any network.
+type TestClient struct {
+	handler http.Handler
+}
+
+// Returns a new test client for the given handler.
+func Client(handler http.Handler) *TestClient {
+	return &TestClient{handler: handler}
+}
+
+// Builds a GET request for the given path.
+func (c *TestClient) GET(path string) *Request {
+	return c.Request(medeina.GET, path)
+}
+
+// Builds a POST request for the given path.
+func (c *TestClient) POST(path string) *Request {
+	return c.Request(medeina.POST, path)
+}
+
+// Builds a PUT request for the given path.
+func (c *TestClient) PUT(path string) *Request {
+	return c.Request(medeina.PUT, path)
+}
+
+// Builds a PATCH request for the given path.
+func (c *TestClient) PATCH(path string) *Request {
+	return c.Request(medeina.PATCH, path)
+}
+
+// Builds a DELETE request for the given path.
+func (c *TestClient) DELETE(path string) *Request {
+	return c.Request(medeina.DELETE, path)
+}
+
+// Builds a request for any method and path.
+func (c *TestClient) Request(method, path string) *Request {
+	return &Request{
+		client: c,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+	}
+}
+
+// Request is a request being built by a TestClient.
+type Request struct {
+	client *TestClient
+	method string
+	path   string
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// Adds a header to the request.
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// Sets the request body.
+func (r *Request) WithBody(body io.Reader) *Request {
+	r.body, r.err = io.ReadAll(body)
+	return r
+}
+
+// Sets the request body to the JSON encoding of v, along with its
+// Content-Type header.
+func (r *Request) WithJSON(v interface{}) *Request {
+	r.body, r.err = json.Marshal(v)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Builds the standard http.Request.
+func (r *Request) HTTPRequest() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	req, err := http.NewRequest(r.method, r.path, bytes.NewReader(r.body))
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = req.URL.RequestURI()
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
+
+// Sends the request and returns its response, ready to be checked.
+func (r *Request) Expect(t testing.TB) *Response {
+	t.Helper()
+	req, err := r.HTTPRequest()
+	if err != nil {
+		t.Fatalf("building request %s %s: %v", r.method, r.path, err)
+	}
+	w := httptest.NewRecorder()
+	r.client.handler.ServeHTTP(w, req)
+	response := &Response{
+		t:        t,
+		request:  req,
+		Recorder: w,
+	}
+	if m, ok := r.client.handler.(Matcher); ok {
+		response.route, response.params, _ = m.Match(req.Method, req.URL.Path)
+	}
+	return response
+}
+
+// Response holds the result of a request and the route which handled it.
+type Response struct {
+	// Recorder holds the raw response.
+	Recorder *httptest.ResponseRecorder
+	t        testing.TB
+	request  *http.Request
+	route    *medeina.Route
+	params   medeina.Params
+}
+
+// Returns the route which handled the request, if the handler was able to
+// tell it.
+func (r *Response) MatchedRoute() *medeina.Route {
+	return r.route
+}
+
+// Returns the params extracted from the request path.
+func (r *Response) Params() medeina.Params {
+	return r.params
+}
+
+// Describes the request and its matched route, to be used in failures.
+func (r *Response) describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", r.request.Method, r.request.URL.Path)
+	if r.route == nil {
+		b.WriteString(" (no route matched)")
+		return b.String()
+	}
+	fmt.Fprintf(&b, " (matched %s %s", r.route.Method, r.route.Path)
+	if len(r.route.Scope) > 0 {
+		fmt.Fprintf(&b, " on %q", r.route.Scope)
+	}
+	for i, p := range r.params {
+		if i == 0 {
+			b.WriteString(" with ")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s=%s", p.Key, p.Value)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Checks the response status code.
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("%s: expected status %d, got %d", r.describe(), code, r.Recorder.Code)
+	}
+	return r
+}
+
+// Checks a response header.
+func (r *Response) Header(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("%s: expected header %s %q, got %q", r.describe(), key, value, got)
+	}
+	return r
+}
+
+// Checks the response body.
+func (r *Response) Body(body string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); got != body {
+		r.t.Errorf("%s: expected body %q, got %q", r.describe(), body, got)
+	}
+	return r
+}
+
+// Checks the response body contains the given string.
+func (r *Response) BodyContains(s string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); !strings.Contains(got, s) {
+		r.t.Errorf("%s: expected body containing %q, got %q", r.describe(), s, got)
+	}
+	return r
+}
+
+// Checks which route pattern handled the request.
+func (r *Response) Route(pattern string) *Response {
+	r.t.Helper()
+	if r.route == nil || r.route.Path != pattern {
+		r.t.Errorf("%s: expected route %s", r.describe(), pattern)
+	}
+	return r
+}
+
+// Checks a param extracted from the request path.
+func (r *Response) Param(name, value string) *Response {
+	r.t.Helper()
+	if got := r.params.ByName(name); got != value {
+		r.t.Errorf("%s: expected param %s %q, got %q", r.describe(), name, value, got)
+	}
+	return r
+}
+
+// Checks a value in the JSON response body. Paths use a small subset of
+// JSONPath: $ for the root, .key for object members and [n] for array
+// elements, e.g. $.items[0].id.
+func (r *Response) JSONPath(path string, want interface{}) *Response {
+	r.t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), &doc); err != nil {
+		r.t.Errorf("%s: decoding JSON body: %v", r.describe(), err)
+		return r
+	}
+	got, err := lookupJSONPath(doc, path)
+	if err != nil {
+		r.t.Errorf("%s: %s: %v", r.describe(), path, err)
+		return r
+	}
+	// Round trip the expected value so numbers and structs compare as
+	// they were decoded from the body.
+	var expected interface{}
+	if b, err := json.Marshal(want); err != nil {
+		r.t.Errorf("%s: encoding expected value: %v", r.describe(), err)
+		return r
+	} else if err := json.Unmarshal(b, &expected); err != nil {
+		r.t.Errorf("%s: decoding expected value: %v", r.describe(), err)
+		return r
+	}
+	if !reflect.DeepEqual(got, expected) {
+		r.t.Errorf("%s: expected %s to be %v, got %v", r.describe(), path, expected, got)
+	}
+	return r
+}
+
+// Walks a decoded JSON document following a JSONPath subset.
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	rest := path[1:]
+	current := doc
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%q is not an object", key)
+			}
+			if current, ok = object[key]; !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [")
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", rest[1:end])
+			}
+			rest = rest[end+1:]
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[%d] is not an array", index)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("index %d out of range", index)
+			}
+			current = array[index]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest[0])
+		}
+	}
+	return current, nil
+}
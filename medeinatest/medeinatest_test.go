@@ -0,0 +1,81 @@
+package medeinatest
+
+import (
+	"encoding/json"
+	"github.com/imdario/medeina"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"testing"
+)
+
+func loadTree() *medeina.Medeina {
+	mr := medeina.NewMedeina()
+	mr.On("repos/:owner/:repo", func() {
+		mr.GET(func() {
+			mr.Is("", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"id":    3,
+					"owner": ps.ByName("owner"),
+					"tags":  []string{"router", "tree"},
+				})
+			})
+		})
+		mr.Is("echo", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("X-Echo", r.Header.Get("X-Echo"))
+			w.WriteHeader(http.StatusCreated)
+		}, medeina.POST)
+	})
+	return mr
+}
+
+func TestTestClient(t *testing.T) {
+	client := Client(loadTree())
+	client.GET("/repos/imdario/medeina").
+		Expect(t).
+		Status(http.StatusOK).
+		Route("/repos/:owner/:repo").
+		Param("owner", "imdario").
+		Param("repo", "medeina").
+		Header("Content-Type", "application/json").
+		JSONPath("$.id", 3).
+		JSONPath("$.owner", "imdario").
+		JSONPath("$.tags[1]", "tree")
+	client.POST("/repos/imdario/medeina/echo").
+		WithHeader("X-Echo", "medeina").
+		Expect(t).
+		Status(http.StatusCreated).
+		Route("/repos/:owner/:repo/echo").
+		Header("X-Echo", "medeina")
+	response := client.GET("/deadmau5").Expect(t).Status(http.StatusNotFound)
+	if response.MatchedRoute() != nil {
+		t.Errorf("Expected no route for /deadmau5, found %v", response.MatchedRoute())
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	response := Client(loadTree()).GET("/repos/imdario/medeina").Expect(t)
+	expected := `GET /repos/imdario/medeina (matched GET /repos/:owner/:repo on ["repos/:owner/:repo"] with owner=imdario, repo=medeina)`
+	if got := response.describe(); got != expected {
+		t.Errorf("Expected description %s, found %s", expected, got)
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"items": [{"id": 1}, {"id": 2, "tags": ["a"]}]}`), &doc)
+	for path, expected := range map[string]interface{}{
+		"$.items[1].id":      2.0,
+		"$.items[1].tags[0]": "a",
+	} {
+		got, err := lookupJSONPath(doc, path)
+		if err != nil || got != expected {
+			t.Errorf("Expected %v for %s, found %v (%v)", expected, path, got, err)
+		}
+	}
+	for _, path := range []string{"items", "$.missing", "$.items[5]", "$.items[0].id.x"} {
+		if _, err := lookupJSONPath(doc, path); err == nil {
+			t.Errorf("Expected error for %s", path)
+		}
+	}
+}
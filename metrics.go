@@ -0,0 +1,167 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsHook observes every request dispatched to a route registered with
+// Is or Handler. Routes are identified by their pattern instead of the raw
+// URL, which keeps the cardinality bounded.
+type MetricsHook interface {
+	Observe(route *Route, status int, elapsed time.Duration)
+}
+
+// Sets the hook observing every dispatched request. A nil hook disables
+// metrics.
+func (m *Medeina) Metrics(hook MetricsHook) {
+	m.metrics = hook
+}
+
+// Upper bounds, in seconds, of the latency histogram buckets.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Labels identifying the metrics of a route.
+type MetricLabels struct {
+	Method string
+	Route  string
+	Scope  string
+}
+
+// Metrics gathered for a route.
+type RouteMetrics struct {
+	MetricLabels
+	// Requests by status class: 1xx, 2xx, 3xx, 4xx and 5xx.
+	Statuses [5]uint64
+	// Requests by latency bucket, cumulative as in Prometheus.
+	Buckets []uint64
+	Count   uint64
+	Sum     time.Duration
+}
+
+// MemoryMetrics is an in-memory MetricsHook. It can export its metrics in
+// Prometheus text format.
+type MemoryMetrics struct {
+	buckets []float64
+	mutex   sync.Mutex
+	routes  map[MetricLabels]*RouteMetrics
+}
+
+// Returns a new in-memory metrics hook. If no buckets are given,
+// DefaultLatencyBuckets are used.
+func NewMemoryMetrics(buckets ...float64) *MemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MemoryMetrics{
+		buckets: buckets,
+		routes:  make(map[MetricLabels]*RouteMetrics),
+	}
+}
+
+func (mm *MemoryMetrics) Observe(route *Route, status int, elapsed time.Duration) {
+	labels := MetricLabels{
+		Method: route.Method,
+		Route:  route.Path,
+		Scope:  strings.Join(route.Scope, " -> "),
+	}
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+	rm, ok := mm.routes[labels]
+	if !ok {
+		rm = &RouteMetrics{
+			MetricLabels: labels,
+			Buckets:      make([]uint64, len(mm.buckets)),
+		}
+		mm.routes[labels] = rm
+	}
+	if class := status/100 - 1; class >= 0 && class < len(rm.Statuses) {
+		rm.Statuses[class]++
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range mm.buckets {
+		if seconds <= bound {
+			rm.Buckets[i]++
+		}
+	}
+	rm.Count++
+	rm.Sum += elapsed
+}
+
+// Returns a copy of the metrics gathered so far, sorted by route and method.
+func (mm *MemoryMetrics) Snapshot() []RouteMetrics {
+	mm.mutex.Lock()
+	snapshot := make([]RouteMetrics, 0, len(mm.routes))
+	for _, rm := range mm.routes {
+		copied := *rm
+		copied.Buckets = append([]uint64(nil), rm.Buckets...)
+		snapshot = append(snapshot, copied)
+	}
+	mm.mutex.Unlock()
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Route != snapshot[j].Route {
+			return snapshot[i].Route < snapshot[j].Route
+		}
+		if snapshot[i].Method != snapshot[j].Method {
+			return snapshot[i].Method < snapshot[j].Method
+		}
+		return snapshot[i].Scope < snapshot[j].Scope
+	})
+	return snapshot
+}
+
+// Writes the metrics in Prometheus text format.
+func (mm *MemoryMetrics) WritePrometheus(w io.Writer) error {
+	snapshot := mm.Snapshot()
+	var b strings.Builder
+	b.WriteString("# HELP medeina_requests_total Requests dispatched by route and status class.\n")
+	b.WriteString("# TYPE medeina_requests_total counter\n")
+	for _, rm := range snapshot {
+		for class, count := range rm.Statuses {
+			if count > 0 {
+				fmt.Fprintf(&b, "medeina_requests_total{%s,status=\"%dxx\"} %d\n", rm.labels(), class+1, count)
+			}
+		}
+	}
+	b.WriteString("# HELP medeina_request_duration_seconds Latency of requests dispatched by route.\n")
+	b.WriteString("# TYPE medeina_request_duration_seconds histogram\n")
+	for _, rm := range snapshot {
+		for i, bound := range mm.buckets {
+			fmt.Fprintf(&b, "medeina_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", rm.labels(), bound, rm.Buckets[i])
+		}
+		fmt.Fprintf(&b, "medeina_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", rm.labels(), rm.Count)
+		fmt.Fprintf(&b, "medeina_request_duration_seconds_sum{%s} %g\n", rm.labels(), rm.Sum.Seconds())
+		fmt.Fprintf(&b, "medeina_request_duration_seconds_count{%s} %d\n", rm.labels(), rm.Count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Serves the metrics in Prometheus text format. Mount it with Is, e.g.:
+//
+// m.Is("metrics", metrics.ServePrometheus, medeina.GET)
+func (mm *MemoryMetrics) ServePrometheus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mm.WritePrometheus(w)
+}
+
+// Formats the labels of a route in Prometheus syntax.
+func (rm *RouteMetrics) labels() string {
+	return fmt.Sprintf("method=\"%s\",route=\"%s\",scope=\"%s\"",
+		escapeLabel(rm.Method), escapeLabel(rm.Route), escapeLabel(rm.Scope))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMemoryMetrics(0.5, 1)
+	mr := NewMedeina()
+	mr.Metrics(metrics)
+	mr.On("repos/:owner/:repo", func() {
+		mr.Is("pulls/:number", testHandlerParams, GET)
+		mr.Is("teapot", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.WriteHeader(http.StatusTeapot)
+		}, GET)
+	})
+	mr.Is("metrics", metrics.ServePrometheus, GET)
+	for _, path := range []string{"/repos/a/b/pulls/1", "/repos/c/d/pulls/2", "/repos/a/b/teapot"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		mr.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected metrics for 2 routes, found %d", len(snapshot))
+	}
+	pulls := snapshot[0]
+	if pulls.Route != "/repos/:owner/:repo/pulls/:number" || pulls.Count != 2 || pulls.Statuses[1] != 2 {
+		t.Errorf("Unexpected pulls metrics %+v", pulls)
+	}
+	if teapot := snapshot[1]; teapot.Statuses[3] != 1 || teapot.Scope != "repos/:owner/:repo" {
+		t.Errorf("Unexpected teapot metrics %+v", teapot)
+	}
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	body := w.Body.String()
+	for _, line := range []string{
+		`medeina_requests_total{method="GET",route="/repos/:owner/:repo/pulls/:number",scope="repos/:owner/:repo",status="2xx"} 2`,
+		`medeina_requests_total{method="GET",route="/repos/:owner/:repo/teapot",scope="repos/:owner/:repo",status="4xx"} 1`,
+		`medeina_request_duration_seconds_bucket{method="GET",route="/repos/:owner/:repo/pulls/:number",scope="repos/:owner/:repo",le="+Inf"} 2`,
+		`medeina_request_duration_seconds_count{method="GET",route="/repos/:owner/:repo/teapot",scope="repos/:owner/:repo"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected line %s in:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies resolves the client address, scheme and host of requests
+// forwarded by proxies in a list of networks.
+type TrustedProxies struct {
+	networks []*net.IPNet
+}
+
+// Parses a list of CIDRs, like 10.0.0.0/8, or single IP addresses.
+func ParseTrustedProxies(cidrs ...string) (*TrustedProxies, error) {
+	tp := &TrustedProxies{}
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			tp.networks = append(tp.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", cidr, err)
+		}
+		tp.networks = append(tp.networks, network)
+	}
+	return tp, nil
+}
+
+// Trusts the Forwarded and X-Forwarded-* headers set by proxies in the
+// given networks. The client IP, scheme and host they tell are stored in
+// the request context, for ClientIP, Scheme and Host.
+func (m *Medeina) TrustProxies(cidrs ...string) error {
+	tp, err := ParseTrustedProxies(cidrs...)
+	if err != nil {
+		return err
+	}
+	m.proxies = tp
+	return nil
+}
+
+// Returns a handler resolving requests before passing them to the given
+// one, for handlers outside trees like HostSwitch. Trees trust proxies on
+// their own with TrustProxies.
+func (tp *TrustedProxies) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, tp.Resolve(r))
+	})
+}
+
+// Tells if an address belongs to a trusted proxy.
+func (tp *TrustedProxies) trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range tp.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Where a request comes from, as told by trusted proxies.
+type origin struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+type originKey struct{}
+
+// Resolves where a request comes from and stores it in its context.
+func (tp *TrustedProxies) Resolve(r *http.Request) *http.Request {
+	o := &origin{
+		ip:     remoteIP(r.RemoteAddr),
+		scheme: "http",
+		host:   r.Host,
+	}
+	if r.TLS != nil {
+		o.scheme = "https"
+	}
+	if tp.trusted(net.ParseIP(o.ip)) {
+		if forwarded := r.Header.Values("Forwarded"); len(forwarded) > 0 {
+			tp.resolveForwarded(o, forwarded)
+		} else if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			tp.resolveXForwarded(o, r.Header)
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), originKey{}, o))
+}
+
+// Walks the Forwarded elements from the nearest hop, stopping at the first
+// untrusted one. The scheme and host are taken from the element added by
+// the last trusted proxy.
+func (tp *TrustedProxies) resolveForwarded(o *origin, values []string) {
+	elements := splitHeader(values)
+	for i := len(elements) - 1; i >= 0; i-- {
+		var ip, proto, host string
+		for _, pair := range strings.Split(elements[i], ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(key) {
+			case "for":
+				ip = forwardedIP(value)
+			case "proto":
+				proto = strings.ToLower(value)
+			case "host":
+				host = value
+			}
+		}
+		if net.ParseIP(ip) == nil {
+			// Unknown or obfuscated hops can't be followed.
+			return
+		}
+		o.ip = ip
+		if proto == "http" || proto == "https" {
+			o.scheme = proto
+		}
+		if host != "" {
+			o.host = host
+		}
+		if !tp.trusted(net.ParseIP(ip)) {
+			return
+		}
+	}
+}
+
+// As resolveForwarded for X-Forwarded-For. X-Forwarded-Proto and
+// X-Forwarded-Host are taken from the same hop when they have a value per
+// hop, or from the nearest one otherwise.
+func (tp *TrustedProxies) resolveXForwarded(o *origin, header http.Header) {
+	hops := splitHeader(header.Values("X-Forwarded-For"))
+	protos := splitHeader(header.Values("X-Forwarded-Proto"))
+	hosts := splitHeader(header.Values("X-Forwarded-Host"))
+	pick := func(values []string, i int) string {
+		if len(values) == len(hops) {
+			return values[i]
+		}
+		if len(values) > 0 {
+			return values[len(values)-1]
+		}
+		return ""
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := forwardedIP(hops[i])
+		if net.ParseIP(ip) == nil {
+			return
+		}
+		o.ip = ip
+		if proto := strings.ToLower(pick(protos, i)); proto == "http" || proto == "https" {
+			o.scheme = proto
+		}
+		if host := pick(hosts, i); host != "" {
+			o.host = host
+		}
+		if !tp.trusted(net.ParseIP(ip)) {
+			return
+		}
+	}
+}
+
+// Splits comma separated header values.
+func splitHeader(values []string) []string {
+	var parts []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return parts
+}
+
+// Strips the port and brackets of a forwarded address.
+func forwardedIP(value string) string {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+	return strings.Trim(value, "[]")
+}
+
+// Returns the IP of a remote address.
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// Returns the client IP resolved through trusted proxies, or an empty
+// string if the tree doesn't trust any.
+func ClientIPFromContext(ctx context.Context) string {
+	if o, ok := ctx.Value(originKey{}).(*origin); ok {
+		return o.ip
+	}
+	return ""
+}
+
+// Returns the scheme, http or https, resolved through trusted proxies, or
+// an empty string if the tree doesn't trust any.
+func SchemeFromContext(ctx context.Context) string {
+	if o, ok := ctx.Value(originKey{}).(*origin); ok {
+		return o.scheme
+	}
+	return ""
+}
+
+// Returns the host resolved through trusted proxies, or an empty string
+// if the tree doesn't trust any.
+func HostFromContext(ctx context.Context) string {
+	if o, ok := ctx.Value(originKey{}).(*origin); ok {
+		return o.host
+	}
+	return ""
+}
+
+// Returns the client IP of a request, resolved through trusted proxies if
+// any, or taken from its remote address.
+func ClientIP(r *http.Request) string {
+	if ip := ClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// Returns the scheme of a request, resolved through trusted proxies if
+// any.
+func Scheme(r *http.Request) string {
+	if scheme := SchemeFromContext(r.Context()); scheme != "" {
+		return scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Returns the host of a request, resolved through trusted proxies if any.
+func Host(r *http.Request) string {
+	if host := HostFromContext(r.Context()); host != "" {
+		return host
+	}
+	return r.Host
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func loadProxyMedeina(t *testing.T) *Medeina {
+	mr := NewMedeina()
+	if err := mr.TrustProxies("10.0.0.0/8", "2001:db8::1"); err != nil {
+		t.Fatalf("Expected valid proxies, found %v", err)
+	}
+	mr.Is("whoami", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		fmt.Fprintf(w, "%s %s %s", ClientIP(r), Scheme(r), Host(r))
+	}, GET)
+	return mr
+}
+
+func TestTrustProxies(t *testing.T) {
+	mr := loadProxyMedeina(t)
+	tests := []struct {
+		remote   string
+		header   http.Header
+		expected string
+	}{
+		{"192.0.2.1:1234", nil, "192.0.2.1 http example.com"},
+		// Untrusted peers can't spoof their address.
+		{"192.0.2.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.7"}}, "192.0.2.1 http example.com"},
+		{"10.0.0.1:1234", http.Header{
+			"X-Forwarded-For":   {"198.51.100.7"},
+			"X-Forwarded-Proto": {"https"},
+			"X-Forwarded-Host":  {"api.example.com"},
+		}, "198.51.100.7 https api.example.com"},
+		// Spoofed hops before the first untrusted one are ignored.
+		{"10.0.0.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7", "10.0.0.2"}}, "198.51.100.7 http example.com"},
+		{"[2001:db8::1]:443", http.Header{
+			"Forwarded": {`for=203.0.113.9, for="[2001:db8:cafe::17]:4711";proto=https;host=medeina.dev`},
+		}, "2001:db8:cafe::17 https medeina.dev"},
+		{"10.0.0.1:1234", http.Header{"Forwarded": {`for=10.0.0.3;proto=https, for=unknown`}}, "10.0.0.1 http example.com"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "http://example.com/whoami", nil)
+		r.RemoteAddr = test.remote
+		for name, values := range test.header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		if w.Body.String() != test.expected {
+			t.Errorf("Expected %q from %s %v, found %q", test.expected, test.remote, test.header, w.Body.String())
+		}
+	}
+}
+
+func TestTrustProxiesKeys(t *testing.T) {
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Errorf("Expected invalid CIDRs to fail")
+	}
+	mr := NewMedeina()
+	mr.TrustProxies("10.0.0.0/8")
+	var key string
+	hosts := HostSwitch{"api.example.com": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = KeyByIP(r, nil)
+	})}
+	mr.Handler("*path", hosts, GET)
+	r, _ := http.NewRequest("GET", "http://internal:8080/", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("X-Forwarded-Host", "api.example.com")
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || key != "198.51.100.7" {
+		t.Errorf("Expected HostSwitch and KeyByIP to use the resolved host and IP, found %d %q", w.Code, key)
+	}
+}
+
+func TestTrustedProxiesHandler(t *testing.T) {
+	tp, _ := ParseTrustedProxies("10.0.0.0/8")
+	mr := NewMedeina()
+	mr.Is("whoami", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		fmt.Fprintf(w, "%s %s", ClientIP(r), Host(r))
+	}, GET)
+	handler := tp.Handler(HostSwitch{"api.example.com": mr})
+	r, _ := http.NewRequest("GET", "http://internal:8080/whoami", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("X-Forwarded-Host", "api.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "198.51.100.7 api.example.com" {
+		t.Errorf("Expected the outermost HostSwitch to use the resolved host, found %d %q", w.Code, w.Body.String())
+	}
+}
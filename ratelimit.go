@@ -0,0 +1,238 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limiting algorithms.
+type RateAlgorithm int
+
+const (
+	// Allows bursts up to the limit, refilling it steadily over the window.
+	TokenBucket RateAlgorithm = iota
+	// Counts requests in the current and previous windows, weighting the
+	// previous one by how much of it still overlaps.
+	SlidingWindow
+)
+
+// Extracts the key requests are limited by.
+type RateKeyFunc func(r *http.Request, route *Route) string
+
+// Limits by client IP, resolved through trusted proxies if any.
+func KeyByIP(r *http.Request, _ *Route) string {
+	return ClientIP(r)
+}
+
+// Limits by the value of a request header, e.g. an API key, falling back
+// to the client IP for requests without it.
+func KeyByHeader(name string) RateKeyFunc {
+	return func(r *http.Request, route *Route) string {
+		if value := r.Header.Get(name); value != "" {
+			return "header:" + value
+		}
+		return KeyByIP(r, route)
+	}
+}
+
+// Limits by authenticated principal, falling back to the client IP for
+// anonymous requests.
+func KeyByPrincipal(r *http.Request, route *Route) string {
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		return "principal:" + principal.Name
+	}
+	return KeyByIP(r, route)
+}
+
+// Limits by route pattern, sharing the limit among all clients.
+func KeyByRoute(_ *http.Request, route *Route) string {
+	return route.Method + " " + route.Path
+}
+
+// RateLimitState is the state kept for each key.
+type RateLimitState struct {
+	// Token bucket.
+	Tokens float64
+	Last   time.Time
+	// Sliding window.
+	Start    time.Time
+	Current  int
+	Previous int
+}
+
+// RateLimitStore keeps the state of the rate limits. Implement it to share
+// limits among several instances.
+type RateLimitStore interface {
+	// Atomically updates the state of a key, which may be dropped once
+	// ttl has elapsed without updates.
+	Update(key string, ttl time.Duration, update func(state *RateLimitState)) error
+}
+
+// RateLimit configures a rate-limit scope.
+type RateLimit struct {
+	// Requests allowed per window.
+	Limit  int
+	Window time.Duration
+	// Defaults to TokenBucket.
+	Algorithm RateAlgorithm
+	// Defaults to KeyByIP.
+	Key RateKeyFunc
+	// Defaults to an in-memory store, shared by the scope.
+	Store RateLimitStore
+	// Shares the limit among all the routes of the scope, and tells it
+	// apart from other scopes using the same store. Without a name, each
+	// route is limited on its own.
+	Name string
+}
+
+// Result of taking a request from a limit.
+type rateDecision struct {
+	allowed   bool
+	remaining int
+	reset     time.Duration
+}
+
+// Applies the algorithm to the state of a key.
+func (rl *RateLimit) take(state *RateLimitState, now time.Time) rateDecision {
+	limit := float64(rl.Limit)
+	if rl.Algorithm == SlidingWindow {
+		if state.Start.IsZero() {
+			state.Start = now.Truncate(rl.Window)
+		}
+		if elapsed := now.Sub(state.Start); elapsed >= rl.Window {
+			if elapsed < 2*rl.Window {
+				state.Previous = state.Current
+			} else {
+				state.Previous = 0
+			}
+			state.Current = 0
+			state.Start = now.Truncate(rl.Window)
+		}
+		weight := 1 - float64(now.Sub(state.Start))/float64(rl.Window)
+		estimate := float64(state.Previous)*weight + float64(state.Current)
+		reset := state.Start.Add(rl.Window).Sub(now)
+		if estimate+1 > limit {
+			return rateDecision{reset: reset}
+		}
+		state.Current++
+		return rateDecision{allowed: true, remaining: int(limit - estimate - 1), reset: reset}
+	}
+	rate := limit / rl.Window.Seconds()
+	if state.Last.IsZero() {
+		state.Tokens = limit
+	} else {
+		state.Tokens = math.Min(limit, state.Tokens+now.Sub(state.Last).Seconds()*rate)
+	}
+	state.Last = now
+	if state.Tokens < 1 {
+		wait := time.Duration((1 - state.Tokens) / rate * float64(time.Second))
+		return rateDecision{reset: wait}
+	}
+	state.Tokens--
+	full := time.Duration((limit - state.Tokens) / rate * float64(time.Second))
+	return rateDecision{allowed: true, remaining: int(state.Tokens), reset: full}
+}
+
+// Wraps the routes of a rate-limit scope. Routes already limited by a
+// deeper scope are left alone, so inner scopes override outer ones.
+func (rl *RateLimit) middleware(route *Route, handle httprouter.Handle) httprouter.Handle {
+	if route.RateLimit != nil {
+		return handle
+	}
+	route.RateLimit = rl
+	scope := "route:" + route.Method + " " + route.Path
+	if rl.Name != "" {
+		scope = "scope:" + rl.Name
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		var decision rateDecision
+		key := scope + "|" + rl.Key(r, route)
+		err := rl.Store.Update(key, rl.Window, func(state *RateLimitState) {
+			decision = rl.take(state, time.Now())
+		})
+		if err != nil {
+			// Failing open is better than blocking everything
+			// when the store is down.
+			handle(w, r, ps)
+			return
+		}
+		header := w.Header()
+		reset := int(math.Ceil(decision.reset.Seconds()))
+		header.Set("RateLimit-Limit", strconv.Itoa(rl.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(reset))
+		if !decision.allowed {
+			header.Set("Retry-After", strconv.Itoa(reset))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
+// Limits the rate of requests to every route registered inside the closure.
+// Requests over the limit get a 429 response with Retry-After. Deeper
+// scopes override the limits of the outer ones.
+func (m *Medeina) RateLimit(limit *RateLimit, handle Handle) {
+	if limit.Limit <= 0 || limit.Window <= 0 {
+		panic(fmt.Errorf("you must set a positive limit and window, found %d per %s", limit.Limit, limit.Window))
+	}
+	rl := *limit
+	if rl.Key == nil {
+		rl.Key = KeyByIP
+	}
+	if rl.Store == nil {
+		rl.Store = NewMemoryRateLimitStore()
+	}
+	m.Use(rl.middleware, handle)
+}
+
+// MemoryRateLimitStore is the default, in-memory RateLimitStore.
+type MemoryRateLimitStore struct {
+	mutex   sync.Mutex
+	states  map[string]*memoryRateState
+	updates int
+}
+
+type memoryRateState struct {
+	RateLimitState
+	expires time.Time
+}
+
+// Returns a new in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		states: make(map[string]*memoryRateState),
+	}
+}
+
+func (s *MemoryRateLimitStore) Update(key string, ttl time.Duration, update func(state *RateLimitState)) error {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// Sweep expired keys from time to time, so the map doesn't grow
+	// forever with clients long gone.
+	s.updates++
+	if s.updates%1024 == 0 {
+		for k, state := range s.states {
+			if now.After(state.expires) {
+				delete(s.states, k)
+			}
+		}
+	}
+	state, ok := s.states[key]
+	if !ok || now.After(state.expires) {
+		state = &memoryRateState{}
+		s.states[key] = state
+	}
+	update(&state.RateLimitState)
+	// Sliding windows look one window back.
+	state.expires = now.Add(2 * ttl)
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	rl := &RateLimit{Limit: 2, Window: time.Second}
+	state := &RateLimitState{}
+	now := time.Now()
+	for i, expected := range []bool{true, true, false} {
+		if decision := rl.take(state, now); decision.allowed != expected {
+			t.Errorf("Request %d: expected allowed=%v", i, expected)
+		}
+	}
+	if decision := rl.take(state, now.Add(500*time.Millisecond)); !decision.allowed || decision.remaining != 0 {
+		t.Errorf("Expected a token refilled after half the window, found %+v", decision)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	rl := &RateLimit{Limit: 4, Window: time.Minute, Algorithm: SlidingWindow}
+	state := &RateLimitState{}
+	start := time.Now().Truncate(time.Minute)
+	for i := 0; i < 4; i++ {
+		rl.take(state, start)
+	}
+	if decision := rl.take(state, start.Add(30*time.Second)); decision.allowed {
+		t.Error("Expected limit exhausted in the current window")
+	}
+	// Half of the previous window still counts: 4 * 0.5 = 2 requests.
+	next := start.Add(90 * time.Second)
+	for i, expected := range []bool{true, true, false} {
+		if decision := rl.take(state, next); decision.allowed != expected {
+			t.Errorf("Request %d: expected allowed=%v", i, expected)
+		}
+	}
+}
+
+func TestRateLimitScopes(t *testing.T) {
+	mr := NewMedeina()
+	mr.RateLimit(&RateLimit{Limit: 1, Window: time.Hour}, func() {
+		mr.On("search", func() {
+			mr.Is("code", testHandler, GET)
+			mr.RateLimit(&RateLimit{Limit: 3, Window: time.Hour, Key: KeyByHeader("X-API-Key")}, func() {
+				mr.Is("users", testHandler, GET)
+			})
+		})
+	})
+	request := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("X-API-Key", "medeina")
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		return w
+	}
+	if w := request("/search/code"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected first request allowed, found Code=%d %v", w.Code, w.Header())
+	}
+	w := request("/search/code")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("Expected second request limited, found Code=%d %v", w.Code, w.Header())
+	}
+	for i := 0; i < 3; i++ {
+		if w := request("/search/users"); w.Code != http.StatusOK {
+			t.Errorf("Expected request %d allowed by the deeper scope, found Code=%d", i, w.Code)
+		}
+	}
+	if w := request("/search/users"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected deeper scope limit enforced, found Code=%d", w.Code)
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, limit := range []*RateLimit{{Window: time.Hour}, {Limit: 1}, {Limit: -1, Window: time.Hour}, {Limit: 1, Window: -time.Hour}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %d per %s to be refused", limit.Limit, limit.Window)
+				}
+			}()
+			NewMedeina().RateLimit(limit, func() {})
+		}()
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	tree := func() *Medeina {
+		mr := NewMedeina()
+		mr.RateLimit(&RateLimit{Limit: 1, Window: time.Hour, Store: store, Name: "search"}, func() {
+			mr.Is("search/code", testHandler, GET)
+			mr.Is("search/users", testHandler, GET)
+		})
+		mr.RateLimit(&RateLimit{Limit: 1, Window: time.Hour, Store: store, Key: KeyByHeader("X-API-Key")}, func() {
+			mr.Is("gists", testHandler, GET)
+			mr.Is("emojis", testHandler, GET)
+		})
+		return mr
+	}
+	request := func(mr *Medeina, path, remote string) int {
+		r, _ := http.NewRequest("GET", path, nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		return w.Code
+	}
+	request(tree(), "/search/code", "192.0.2.1:1234")
+	// Rebuilt trees keep the state of their scopes in shared stores.
+	mr := tree()
+	if code := request(mr, "/search/users", "192.0.2.1:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the named scope limit shared by its routes, found Code=%d", code)
+	}
+	request(mr, "/gists", "192.0.2.1:1234")
+	if code := request(mr, "/emojis", "192.0.2.1:1234"); code != http.StatusOK {
+		t.Errorf("Expected unnamed scopes to limit each route, found Code=%d", code)
+	}
+	if code := request(mr, "/gists", "192.0.2.2:1234"); code != http.StatusOK {
+		t.Errorf("Expected requests without the header limited by IP, found Code=%d", code)
+	}
+	if code := request(mr, "/gists", "192.0.2.1:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the limit of the IP enforced, found Code=%d", code)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Option configures a tree created with NewMedeina.
+type Option func(*Medeina)
+
+// RequestIDs configures the request IDs assigned by the tree.
+type RequestIDs struct {
+	// Header read from requests and echoed in responses. Defaults to
+	// X-Request-ID.
+	Header string
+	// Generates IDs for requests without a valid one. Defaults to NewULID.
+	Generate func() string
+	// Tells if an incoming ID can be trusted. Defaults to IDs of up to 128
+	// letters, digits, dots, colons, dashes and underscores.
+	Validate func(id string) bool
+}
+
+type requestIDKey struct{}
+
+// Returns the request ID stored in the context, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Returns a copy of the context holding the request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Assigns an ID to every request, read from the configured header if it's
+// valid or generated otherwise. It is echoed in the response and stored
+// in the request context, where RequestIDFromContext finds it.
+func WithRequestID(ids *RequestIDs) Option {
+	return func(m *Medeina) {
+		m.requestIDs = ids
+	}
+}
+
+// Recovers the panics of handlers, passing them to the given function with
+// the request as routed, so its context holds the request ID and the
+// origin resolved through trusted proxies. http.ErrAbortHandler is still
+// raised, as it aborts the response on purpose.
+func WithPanicHandler(handler func(w http.ResponseWriter, r *http.Request, p interface{})) Option {
+	return func(m *Medeina) {
+		m.router.PanicHandler = func(w http.ResponseWriter, r *http.Request, p interface{}) {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			handler(w, r, p)
+		}
+	}
+}
+
+func (ids *RequestIDs) header() string {
+	if ids.Header == "" {
+		return "X-Request-ID"
+	}
+	return ids.Header
+}
+
+// Reads or generates the ID of a request.
+func (ids *RequestIDs) assign(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(ids.header())
+	validate := ids.Validate
+	if validate == nil {
+		validate = validRequestID
+	}
+	if id == "" || !validate(id) {
+		if ids.Generate != nil {
+			id = ids.Generate()
+		} else {
+			id = NewULID()
+		}
+	}
+	w.Header().Set(ids.header(), id)
+	return r.WithContext(ContextWithRequestID(r.Context(), id))
+}
+
+// Default validation of incoming request IDs.
+func validRequestID(id string) bool {
+	if len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.' || c == ':' || c == '-' || c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Crockford's base 32, as used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Returns a new ULID: 26 characters, sortable by creation time.
+func NewULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+	// 128 bits in 26 characters of 5 bits, the first one taking only 3.
+	id := make([]byte, 26)
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		id[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id)
+}
+
+// Returns a new random UUID, version 4.
+func NewUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	var id [36]byte
+	hex.Encode(id[:8], b[:4])
+	id[8] = '-'
+	hex.Encode(id[9:13], b[4:6])
+	id[13] = '-'
+	hex.Encode(id[14:18], b[6:8])
+	id[18] = '-'
+	hex.Encode(id[19:23], b[8:10])
+	id[23] = '-'
+	hex.Encode(id[24:], b[10:])
+	return string(id[:])
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	tracer := NewMemoryTracer()
+	mr := NewMedeina(WithRequestID(&RequestIDs{}))
+	mr.Tracer(tracer)
+	mr.Is("users/:user", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		seen = RequestIDFromContext(r.Context())
+	}, GET)
+	r, _ := http.NewRequest("GET", "/users/imdario", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	id := w.Header().Get("X-Request-ID")
+	if !regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`).MatchString(id) || seen != id {
+		t.Errorf("Expected a generated ULID stored in the context, found %q and %q", id, seen)
+	}
+	if spans := tracer.Spans(); len(spans) != 1 || spans[0].Attributes["medeina.request_id"] != id {
+		t.Errorf("Expected the span to hold the request ID %q", id)
+	}
+	r, _ = http.NewRequest("GET", "/users/imdario", nil)
+	r.Header.Set("X-Request-ID", "upstream-42")
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Header().Get("X-Request-ID") != "upstream-42" || seen != "upstream-42" {
+		t.Errorf("Expected the incoming ID to be kept, found %q", w.Header().Get("X-Request-ID"))
+	}
+	r, _ = http.NewRequest("GET", "/users/imdario", nil)
+	r.Header.Set("X-Request-ID", "<script>")
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if id := w.Header().Get("X-Request-ID"); id == "<script>" || len(id) != 26 {
+		t.Errorf("Expected invalid IDs to be replaced, found %q", id)
+	}
+}
+
+func TestRequestIDPanics(t *testing.T) {
+	var reported string
+	mr := NewMedeina(WithRequestID(&RequestIDs{}), WithPanicHandler(func(w http.ResponseWriter, r *http.Request, p interface{}) {
+		reported = fmt.Sprintf("%s: %v", RequestIDFromContext(r.Context()), p)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	mr.Is("boom", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		panic("boom")
+	}, GET)
+	r, _ := http.NewRequest("GET", "/boom", nil)
+	r.Header.Set("X-Request-ID", "upstream-42")
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || reported != "upstream-42: boom" {
+		t.Errorf("Expected the panic reported with the request ID, found Code=%d %q", w.Code, reported)
+	}
+}
+
+func TestRequestIDOptions(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	mr := NewMedeina(WithRequestID(&RequestIDs{
+		Header:   "X-Correlation-ID",
+		Generate: NewUUID,
+		Validate: func(id string) bool { return strings.HasPrefix(id, "trusted-") },
+	}))
+	mr.AccessLog(&AccessLogger{Logger: slog.New(slog.NewJSONHandler(buffer, nil))}, func() {
+		mr.Is("users/:user", testHandler, GET)
+	})
+	r, _ := http.NewRequest("GET", "/users/imdario", nil)
+	r.Header.Set("X-Correlation-ID", "untrusted")
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	id := w.Header().Get("X-Correlation-ID")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("Expected a generated UUIDv4, found %q", id)
+	}
+	if !strings.Contains(buffer.String(), `"request_id":"`+id+`"`) {
+		t.Errorf("Expected the access log to use the assigned ID %q, found %s", id, buffer.String())
+	}
+}
+
+func TestULIDOrder(t *testing.T) {
+	previous := NewULID()
+	for i := 0; i < 100; i++ {
+		id := NewULID()
+		if id[:10] < previous[:10] {
+			t.Errorf("Expected ULIDs sorted by time, found %s after %s", id, previous)
+		}
+		previous = id
+	}
+}
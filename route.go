@@ -0,0 +1,163 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Route describes an endpoint registered in the routing tree.
+type Route struct {
+	// HTTP method the route answers to.
+	Method string
+	// Full path as registered in httprouter, e.g. /repos/:owner/:repo.
+	Path string
+	// Chain of On subpaths which lead to the route.
+	Scope []string
+	// Method of the innermost GET, POST, etc. closure in which the route
+	// was registered, if any.
+	MethodScope string
+	// Tells if the route requires an authenticated principal.
+	Protected bool
+	// Tells if the route checks authorization policies.
+	Authorized bool
+	// Tells if the route answers cross-origin requests.
+	CORS bool
+	// Rate limit applied to the route, if any.
+	RateLimit *RateLimit
+	// Timeout applied to the route, if any.
+	Timeout time.Duration
+	// Validation applied to requests, if any.
+	Validation *Validation
+	// Compression applied to responses, if any.
+	Compression *Compression
+	// Tells if the route answers conditional requests.
+	Conditional bool
+	// Tells if the route responses are cached, or purge the cache.
+	Cached bool
+	// Tells if the route upgrades to a WebSocket connection.
+	WebSocket bool
+	// Tells if the route streams Server-Sent Events.
+	SSE bool
+	// Version of the tree the route belongs to, if versioned.
+	Version string
+	// Deprecation of the route, if any.
+	Deprecation *Deprecation
+	// Schema of the route, if described.
+	Schema *Schema
+
+	// Hits of deprecated routes.
+	hits *atomic.Uint64
+}
+
+// Returns short labels describing the features enabled on the route.
+func (rt *Route) Flags() []string {
+	var flags []string
+	if rt.Protected {
+		flags = append(flags, "protected")
+	}
+	if rt.Authorized {
+		flags = append(flags, "authorized")
+	}
+	if rt.CORS {
+		flags = append(flags, "cors")
+	}
+	if rt.RateLimit != nil {
+		flags = append(flags, "rate-limited")
+	}
+	if rt.Timeout != 0 {
+		flags = append(flags, "timeout="+rt.Timeout.String())
+	}
+	if rt.Validation != nil {
+		flags = append(flags, "validated")
+	}
+	if rt.Compression != nil && !rt.Compression.Disabled {
+		flags = append(flags, "compressed")
+	}
+	if rt.Conditional {
+		flags = append(flags, "conditional")
+	}
+	if rt.Cached {
+		flags = append(flags, "cached")
+	}
+	if rt.WebSocket {
+		flags = append(flags, "websocket")
+	}
+	if rt.SSE {
+		flags = append(flags, "sse")
+	}
+	if rt.Version != "" {
+		flags = append(flags, "version="+rt.Version)
+	}
+	if rt.Deprecation != nil {
+		flags = append(flags, "deprecated")
+	}
+	return flags
+}
+
+// Fills the route path with the given params. It is used to find which
+// pattern matched a path, as httprouter doesn't tell it.
+func (rt *Route) expand(ps Params) string {
+	segments := strings.Split(rt.Path, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		switch segment[0] {
+		case ':':
+			segments[i] = ps.ByName(segment[1:])
+		case '*':
+			// Catch-all values include their leading slash.
+			segments[i] = strings.TrimPrefix(ps.ByName(segment[1:]), "/")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Returns all routes registered in the tree, in registration order.
+func (m *Medeina) Routes() []Route {
+	routes := make([]Route, len(m.routes))
+	for i, route := range m.routes {
+		routes[i] = *route
+	}
+	return routes
+}
+
+// Returns the route which would handle a request with the given method and
+// path, along with the params extracted from the path.
+func (m *Medeina) Match(method, path string) (*Route, Params, bool) {
+	handle, ps, _ := m.router.Lookup(method, path)
+	if handle == nil {
+		return nil, nil, false
+	}
+	params := Params(ps)
+	for _, route := range m.routes {
+		if route.Method == method && route.expand(params) == path {
+			return route, params, true
+		}
+	}
+	return nil, params, false
+}
+
+// Wraps the handle with the logic shared by every route in the tree.
+func (m *Medeina) dispatch(route *Route, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if m.debug != nil {
+			annotate(w, route)
+		}
+		if span := SpanFromContext(r.Context()); span != nil {
+			traceRoute(span, route, Params(ps))
+		}
+		if m.metrics != nil {
+			rw := newResponseWriter(w)
+			start := time.Now()
+			handle(rw, r, ps)
+			m.metrics.Observe(route, rw.Status(), time.Since(start))
+			return
+		}
+		handle(w, r, ps)
+	}
+}
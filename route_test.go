@@ -0,0 +1,77 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	mr := medeina.(*Medeina)
+	routes := mr.Routes()
+	if len(routes) == 0 {
+		t.Fatal("Expected registered routes")
+	}
+	// Every pattern must match itself, as params take their own names as values.
+	for _, route := range routes {
+		found, _, ok := mr.Match(route.Method, route.Path)
+		if !ok || found.Path != route.Path {
+			t.Errorf("Route %s %s doesn't match itself", route.Method, route.Path)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	mr := medeina.(*Medeina)
+	route, ps, ok := mr.Match("GET", "/repos/imdario/medeina/pulls/42/files")
+	if !ok {
+		t.Fatal("Expected a matching route")
+	}
+	if route.Path != "/repos/:owner/:repo/pulls/:number/files" {
+		t.Errorf("Unexpected route %s", route.Path)
+	}
+	if ps.ByName("number") != "42" {
+		t.Errorf("Unexpected params %v", ps)
+	}
+	if len(route.Scope) != 2 || route.Scope[1] != "pulls/:number" {
+		t.Errorf("Unexpected scope %q", route.Scope)
+	}
+	if _, _, ok := mr.Match("PATCH", "/pulls"); ok {
+		t.Error("Not expected route PATCH /pulls found")
+	}
+	route, ps, ok = standard.(*Medeina).Match("GET", "/api/v1/events/list")
+	if !ok || route.Path != "/api/v1/events/*medeina_subpath" || ps.ByName("medeina_subpath") != "/list" {
+		t.Errorf("Unexpected catch-all match %v %v", route, ps)
+	}
+}
+
+func TestUse(t *testing.T) {
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(route *Route, handle httprouter.Handle) httprouter.Handle {
+			return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+				order = append(order, name)
+				handle(w, r, ps)
+			}
+		}
+	}
+	mr := NewMedeina()
+	mr.Use(middleware("outer"), func() {
+		mr.On("gists", func() {
+			mr.Use(middleware("inner"), func() {
+				mr.Is(":id", testHandler, GET)
+			})
+			mr.Is("", testHandler, GET)
+		})
+	})
+	for _, path := range []string{"/gists/1", "/gists"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		mr.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if strings.Join(order, " ") != "outer inner outer" {
+		t.Errorf("Unexpected middleware order %q", order)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+)
+
+// Schema describes what routes take and return, for generated clients.
+type Schema struct {
+	// Name of the operation, like GetPull. Generators derive one from the
+	// method and path if empty.
+	Name string
+	// Values of the types of the JSON request and response bodies, like
+	// NewPull{} or []Pull{}. Nil means no body.
+	Request  interface{}
+	Response interface{}
+	// Go types of the path params, by param name, like int for :number.
+	// Missing params are strings.
+	Params map[string]string
+}
+
+// Describes every route registered inside the closure, usually a single
+// one. It doesn't change how requests are served. Deeper scopes override
+// the outer ones.
+func (m *Medeina) Describe(schema *Schema, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		if route.Schema == nil {
+			route.Schema = schema
+		}
+		return handle
+	}, handle)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// Sent as id, and as Last-Event-ID by clients when they reconnect.
+	ID string
+	// Sent as event. Clients default to "message".
+	Name string
+	// Sent as one data line per line.
+	Data string
+	// Tells clients how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// EventStream sends events to a client connected to a SSE route.
+type EventStream interface {
+	// Sends an event and flushes it. It fails once the client is gone.
+	Send(event Event) error
+	// Returns the ID of the last event the client got, if it's
+	// reconnecting.
+	LastEventID() string
+	// Closed when the client disconnects.
+	Done() <-chan struct{}
+}
+
+// SSEOptions configures SSE routes.
+type SSEOptions struct {
+	// Interval between comments sent to keep idle connections open.
+	// Defaults to 15 seconds; negative disables them.
+	Heartbeat time.Duration
+	// Reconnection delay sent to clients when the stream starts.
+	Retry time.Duration
+}
+
+// SSEHandle serves a stream of events. The stream ends when it returns.
+type SSEHandle func(stream EventStream, r *http.Request, ps Params)
+
+// Registers a Server-Sent Events endpoint for GET requests in the current
+// context, sending heartbeats every 15 seconds.
+func (m *Medeina) SSE(path string, handle SSEHandle) {
+	m.SSEWith(path, &SSEOptions{}, handle)
+}
+
+// As SSE but with the given options.
+func (m *Medeina) SSEWith(path string, opts *SSEOptions, handle SSEHandle) {
+	heartbeat := opts.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = 15 * time.Second
+	}
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.SSE = true
+		return handle
+	}, func() {
+		m.Is(path, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			header := w.Header()
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("Cache-Control", "no-cache")
+			// Stops proxies like nginx from buffering the stream.
+			header.Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+			stream := &eventStream{
+				w:          w,
+				controller: http.NewResponseController(w),
+				request:    r,
+			}
+			if opts.Retry > 0 {
+				stream.write([]byte("retry: " + strconv.FormatInt(opts.Retry.Milliseconds(), 10) + "\n\n"))
+			} else {
+				stream.write(nil)
+			}
+			if heartbeat > 0 {
+				stop := stream.heartbeat(heartbeat)
+				defer stop()
+			}
+			handle(stream, r, Params(ps))
+		}, GET)
+	})
+}
+
+type eventStream struct {
+	mutex sync.Mutex
+	w     http.ResponseWriter
+	// Flushes through the writers of middlewares, as long as they allow
+	// unwrapping.
+	controller *http.ResponseController
+	request    *http.Request
+}
+
+func (s *eventStream) LastEventID() string {
+	return s.request.Header.Get("Last-Event-ID")
+}
+
+func (s *eventStream) Done() <-chan struct{} {
+	return s.request.Context().Done()
+}
+
+func (s *eventStream) Send(event Event) error {
+	var b bytes.Buffer
+	if event.ID != "" {
+		b.WriteString("id: " + sanitizeEventField(event.ID) + "\n")
+	}
+	if event.Name != "" {
+		b.WriteString("event: " + sanitizeEventField(event.Name) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	data := strings.ReplaceAll(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.Bytes())
+}
+
+// Writes and flushes a chunk of the stream.
+func (s *eventStream) write(b []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.request.Context().Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// Sends comments periodically until the returned function is called.
+func (s *eventStream) heartbeat(interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.write([]byte(": heartbeat\n\n")) != nil {
+					return
+				}
+			case <-done:
+				return
+			case <-s.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		// The handler can't return while the writer is still in use.
+		<-stopped
+	}
+}
+
+// Removes line breaks, which would end a field.
+func sanitizeEventField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "", "\x00", "").Replace(value)
+}
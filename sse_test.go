@@ -0,0 +1,108 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reads lines until a blank one, returning the event read.
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected an event, found %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	release := make(chan struct{})
+	disconnected := make(chan struct{})
+	mr := NewMedeina()
+	mr.On("repos/:owner/:repo", func() {
+		// Buffering scopes must let events through as they're flushed.
+		mr.Conditional(&Conditional{}, func() {
+			mr.Compress(&Compression{}, func() {
+				mr.SSEWith("events", &SSEOptions{Heartbeat: -1, Retry: 3 * time.Second}, func(stream EventStream, r *http.Request, ps Params) {
+					id, _ := strconv.Atoi(stream.LastEventID())
+					stream.Send(Event{ID: strconv.Itoa(id + 1), Name: "push", Data: ps.ByName("repo") + "\nline two"})
+					<-release
+					stream.Send(Event{ID: strconv.Itoa(id + 2), Data: "second"})
+					<-stream.Done()
+					close(disconnected)
+				})
+			})
+		})
+	})
+	server := httptest.NewServer(mr)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/repos/julienschmidt/httprouter/events", nil)
+	r.Header.Set("Last-Event-ID", "41")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("Expected the stream to open, found %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, found %q", ct)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Expected Cache-Control: no-cache, found %q", cc)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if event := readEvent(t, reader); event != "retry: 3000\n" {
+		t.Errorf("Expected the retry delay, found %q", event)
+	}
+	if event := readEvent(t, reader); event != "id: 42\nevent: push\ndata: httprouter\ndata: line two\n" {
+		t.Errorf("Expected the first event before the handler goes on, found %q", event)
+	}
+	close(release)
+	if event := readEvent(t, reader); event != "id: 43\ndata: second\n" {
+		t.Errorf("Expected the second event, found %q", event)
+	}
+	cancel()
+	resp.Body.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the handler to notice the disconnection")
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	mr := NewMedeina()
+	mr.SSEWith("events", &SSEOptions{Heartbeat: 5 * time.Millisecond}, func(stream EventStream, r *http.Request, ps Params) {
+		<-stream.Done()
+		if err := stream.Send(Event{Data: "gone"}); err == nil {
+			t.Errorf("Expected sending after disconnection to fail")
+		}
+	})
+	server := httptest.NewServer(mr)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("Expected the stream to open, found %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if event := readEvent(t, reader); event != ": heartbeat\n" {
+		t.Errorf("Expected a heartbeat, found %q", event)
+	}
+	for _, route := range mr.Routes() {
+		if flags := strings.Join(route.Flags(), ","); flags != "sse" {
+			t.Errorf("Expected a SSE route, found [%s]", flags)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOptions configures how Static serves files.
+type StaticOptions struct {
+	// Sets Cache-Control max-age. Zero sends no Cache-Control.
+	MaxAge time.Duration
+	// Adds immutable to Cache-Control, for fingerprinted assets.
+	Immutable bool
+	// Allows directory listings, banned by default.
+	Browse bool
+	// File served for directories. Defaults to index.html.
+	Index string
+	// File served, with status 200, for unmatched paths without
+	// extension under the prefix, as single-page apps expect. Usually
+	// index.html.
+	Fallback string
+	// Ignores precompressed .br and .gz siblings.
+	IgnorePrecompressed bool
+}
+
+// Precompressed siblings, in order of preference.
+var precompressed = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Serves the files of fsys, either an embed.FS or an os.DirFS, under the
+// given subpath, relative to the current context as in On. It registers
+// GET and HEAD routes with a catch-all param, so subpath can't be shared
+// with other routes.
+func (m *Medeina) Static(subpath string, fsys fs.FS, opts *StaticOptions) {
+	if opts == nil {
+		opts = &StaticOptions{}
+	}
+	s := &static{
+		fsys:  fsys,
+		opts:  opts,
+		etags: make(map[string]string),
+	}
+	if subpath = strings.Trim(subpath, "/"); subpath == "" {
+		m.Is("*medeina_filepath", s.serve, GET, HEAD)
+		return
+	}
+	m.On(subpath, func() {
+		m.Is("*medeina_filepath", s.serve, GET, HEAD)
+	})
+}
+
+type static struct {
+	fsys  fs.FS
+	opts  *StaticOptions
+	mutex sync.Mutex
+	// Hashed ETags of files without modification time, as in embed.FS.
+	etags map[string]string
+}
+
+func (s *static) index() string {
+	if s.opts.Index == "" {
+		return "index.html"
+	}
+	return s.opts.Index
+}
+
+func (s *static) serve(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := strings.TrimPrefix(path.Clean("/"+ps.ByName("medeina_filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err == nil && info.IsDir() {
+		index := path.Join(name, s.index())
+		if indexInfo, err := fs.Stat(s.fsys, index); err == nil && !indexInfo.IsDir() {
+			name, info = index, indexInfo
+		} else if s.opts.Browse {
+			s.list(w, r, name)
+			return
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if err != nil {
+		if s.opts.Fallback == "" || path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name = s.opts.Fallback
+		if info, err = fs.Stat(s.fsys, name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	s.serveFile(w, r, name, info)
+}
+
+// Serves a file, or its best precompressed sibling accepted by the client.
+func (s *static) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	header := w.Header()
+	served := name
+	if !s.opts.IgnorePrecompressed {
+		header.Add("Vary", "Accept-Encoding")
+		qualities := encodingQualities(r.Header.Get("Accept-Encoding"))
+		for _, pc := range precompressed {
+			if encodingQuality(qualities, pc.encoding) <= 0 {
+				continue
+			}
+			if pcInfo, err := fs.Stat(s.fsys, name+pc.extension); err == nil && !pcInfo.IsDir() {
+				header.Set("Content-Encoding", pc.encoding)
+				served, info = name+pc.extension, pcInfo
+				break
+			}
+		}
+	}
+	if s.opts.MaxAge > 0 {
+		cc := fmt.Sprintf("public, max-age=%d", int(s.opts.MaxAge.Seconds()))
+		if s.opts.Immutable {
+			cc += ", immutable"
+		}
+		header.Set("Cache-Control", cc)
+	}
+	f, err := s.fsys.Open(served)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	if info.ModTime().IsZero() && header.Get("ETag") == "" {
+		if etag, err := s.etag(served, content); err == nil {
+			header.Set("ETag", etag)
+		}
+	}
+	// The original name sets the content type, not the compressed one.
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// Returns the ETag of a file, hashing it the first time.
+func (s *static) etag(name string, content io.ReadSeeker) (string, error) {
+	s.mutex.Lock()
+	etag, ok := s.etags[name]
+	s.mutex.Unlock()
+	if ok {
+		return etag, nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag = `"` + base64.RawURLEncoding.EncodeToString(hash.Sum(nil)[:12]) + `"`
+	s.mutex.Lock()
+	s.etags[name] = etag
+	s.mutex.Unlock()
+	return etag, nil
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Name}}</title></head>
+<body>
+<pre>
+{{range .Entries}}<a href="{{.}}">{{.}}</a>
+{{end}}</pre>
+</body>
+</html>
+`))
+
+// Lists a directory, when browsing is allowed.
+func (s *static) list(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name()+"/")
+		} else {
+			names = append(names, entry.Name())
+		}
+	}
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	listingTemplate.Execute(w, struct {
+		Name    string
+		Entries []string
+	}{r.URL.Path, names})
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticFS = fstest.MapFS{
+	"index.html":        {Data: []byte("<html>app</html>")},
+	"app.js":            {Data: []byte("console.log('app');")},
+	"app.js.gz":         {Data: []byte("gzipped")},
+	"css/site.css":      {Data: []byte("body {}")},
+	"docs/readme.txt":   {Data: []byte("readme")},
+	"images/logo.svg":   {Data: []byte("<svg></svg>")},
+	"images/index.html": {Data: []byte("gallery")},
+}
+
+func staticRequest(mr *Medeina, method, path string, header http.Header) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticFiles(t *testing.T) {
+	mr := NewMedeina()
+	mr.On("assets", func() {
+		mr.Static("", staticFS, &StaticOptions{MaxAge: time.Hour, Immutable: true})
+	})
+	w := staticRequest(mr, "GET", "/assets/css/site.css", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "body {}" {
+		t.Errorf("Expected 200 with the stylesheet, found %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Expected text/css, found %q", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=3600, immutable" {
+		t.Errorf("Expected immutable Cache-Control, found %q", cc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Errorf("Expected an ETag for a file without modification time")
+	}
+	w = staticRequest(mr, "GET", "/assets/css/site.css", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, found %d", w.Code)
+	}
+	w = staticRequest(mr, "HEAD", "/assets/app.js", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected 200 without body for HEAD, found %d %q", w.Code, w.Body.String())
+	}
+	w = staticRequest(mr, "GET", "/assets/images/", nil)
+	if w.Body.String() != "gallery" {
+		t.Errorf("Expected the directory index, found %q", w.Body.String())
+	}
+	w = staticRequest(mr, "GET", "/assets/docs/", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected directory listings to be banned, found %d", w.Code)
+	}
+	w = staticRequest(mr, "GET", "/assets/../medeina.go", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 escaping the file system, found %d", w.Code)
+	}
+	w = staticRequest(mr, "GET", "/assets/missing", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without fallback, found %d", w.Code)
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	mr := NewMedeina()
+	mr.Static("static", staticFS, nil)
+	w := staticRequest(mr, "GET", "/static/app.js", http.Header{"Accept-Encoding": {"gzip, deflate"}})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Body.String() != "gzipped" {
+		t.Errorf("Expected the precompressed sibling, found %q %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("Expected the content type of the original file, found %q", ct)
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, found %q", w.Header().Get("Vary"))
+	}
+	w = staticRequest(mr, "GET", "/static/app.js", nil)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "console.log('app');" {
+		t.Errorf("Expected the plain file, found %q %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+	w = staticRequest(mr, "GET", "/static/app.js", http.Header{"Accept-Encoding": {"gzip;q=0, deflate"}})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "console.log('app');" {
+		t.Errorf("Expected the plain file for refused gzip, found %q %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "" {
+		t.Errorf("Expected no Cache-Control without MaxAge, found %q", w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestStaticFallback(t *testing.T) {
+	mr := NewMedeina()
+	mr.Static("/", staticFS, &StaticOptions{Fallback: "index.html", Browse: true})
+	w := staticRequest(mr, "GET", "/users/42/settings", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "<html>app</html>" {
+		t.Errorf("Expected the SPA fallback, found %d %q", w.Code, w.Body.String())
+	}
+	w = staticRequest(mr, "GET", "/missing.js", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing assets, found %d", w.Code)
+	}
+	w = staticRequest(mr, "GET", "/docs/", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="readme.txt">`) {
+		t.Errorf("Expected a directory listing, found %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Swappable serves a tree which can be replaced while serving, e.g. when
+// routes are loaded from config. Requests keep the tree they started on.
+type Swappable struct {
+	current atomic.Pointer[servedTree]
+}
+
+// A tree with the requests it is serving.
+type servedTree struct {
+	tree     *Medeina
+	active   atomic.Int64
+	retired  atomic.Bool
+	drained  chan struct{}
+	drainOne sync.Once
+}
+
+func newServedTree(m *Medeina) *servedTree {
+	return &servedTree{tree: m, drained: make(chan struct{})}
+}
+
+// Marks a request as done, telling a retired tree once it has no more.
+func (st *servedTree) release() {
+	if st.active.Add(-1) == 0 && st.retired.Load() {
+		st.drainOne.Do(func() { close(st.drained) })
+	}
+}
+
+// Returns a handler serving the given tree until it is swapped.
+func NewSwappable(m *Medeina) *Swappable {
+	s := &Swappable{}
+	s.current.Store(newServedTree(m))
+	return s
+}
+
+// Returns the tree being served.
+func (s *Swappable) Current() *Medeina {
+	return s.current.Load().tree
+}
+
+func (s *Swappable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st := s.acquire()
+	defer st.release()
+	st.tree.ServeHTTP(w, r)
+}
+
+// Counts a request in the current tree. If it was swapped meanwhile, the
+// request moves to the new one, as the swap may not be waiting for it.
+func (s *Swappable) acquire() *servedTree {
+	for {
+		st := s.current.Load()
+		st.active.Add(1)
+		if s.current.Load() == st {
+			return st
+		}
+		st.release()
+	}
+}
+
+// Replaces the tree served. New requests go to the new tree at once, while
+// it waits until the requests in flight on the old one finish, or the
+// context is done. It returns how the routes changed, even if the context
+// expired before draining the old tree.
+func (s *Swappable) Swap(ctx context.Context, m *Medeina) (RouteDiff, error) {
+	old := s.current.Swap(newServedTree(m))
+	diff := DiffRoutes(old.tree.Routes(), m.Routes())
+	old.retired.Store(true)
+	if old.active.Load() == 0 {
+		old.drainOne.Do(func() { close(old.drained) })
+	}
+	select {
+	case <-old.drained:
+		return diff, nil
+	case <-ctx.Done():
+		return diff, ctx.Err()
+	}
+}
+
+// RouteDiff tells how the routes of a tree changed.
+type RouteDiff struct {
+	Added   []Route
+	Removed []Route
+	// Routes with the same method and path but different scopes or
+	// features, as found in the new tree.
+	Changed []Route
+}
+
+// Tells if both trees have the same routes.
+func (d RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Lists the changes one per line, prefixed by +, - or ~.
+func (d RouteDiff) String() string {
+	var b strings.Builder
+	for _, change := range []struct {
+		prefix string
+		routes []Route
+	}{{"+", d.Added}, {"-", d.Removed}, {"~", d.Changed}} {
+		for _, route := range change.routes {
+			fmt.Fprintf(&b, "%s %s %s", change.prefix, route.Method, route.Path)
+			if flags := route.Flags(); len(flags) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(flags, ", "))
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// Compares two sets of routes, as returned by Routes.
+func DiffRoutes(before, after []Route) RouteDiff {
+	var diff RouteDiff
+	key := func(route Route) string {
+		return route.Method + " " + route.Path
+	}
+	previous := make(map[string]Route, len(before))
+	for _, route := range before {
+		previous[key(route)] = route
+	}
+	kept := make(map[string]bool, len(after))
+	for _, route := range after {
+		kept[key(route)] = true
+		old, ok := previous[key(route)]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, route)
+		case strings.Join(old.Scope, "/") != strings.Join(route.Scope, "/") || strings.Join(old.Flags(), ",") != strings.Join(route.Flags(), ","):
+			diff.Changed = append(diff.Changed, route)
+		}
+	}
+	for _, route := range before {
+		if !kept[key(route)] {
+			diff.Removed = append(diff.Removed, route)
+		}
+	}
+	return diff
+}
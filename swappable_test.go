@@ -0,0 +1,78 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSwappable(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	old := NewMedeina()
+	old.GET(func() {
+		old.Is("slow", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			close(started)
+			<-release
+			fmt.Fprint(w, "old slow")
+		})
+		old.Is("users", testHandler)
+		old.Is("gists", testHandler)
+	})
+	current := NewMedeina()
+	current.GET(func() {
+		current.Is("slow", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			fmt.Fprint(w, "new slow")
+		})
+		current.Is("repos", testHandler)
+		current.Validate(&Validation{MaxBodySize: 1024}, func() {
+			current.Is("users", testHandler)
+		})
+	})
+	s := NewSwappable(old)
+	inFlight := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		r, _ := http.NewRequest("GET", "/slow", nil)
+		s.ServeHTTP(inFlight, r)
+		close(finished)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	diff, err := s.Swap(ctx, current)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the swap to wait for the request in flight, found %v", err)
+	}
+	if s.Current() != current {
+		t.Errorf("Expected the new tree to be served")
+	}
+	expected := "+ GET /repos\n- GET /gists\n~ GET /users [validated]\n"
+	if diff.String() != expected {
+		t.Errorf("Expected diff %q, found %q", expected, diff.String())
+	}
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Body.String() != "new slow" {
+		t.Errorf("Expected new requests on the new tree, found %q", w.Body.String())
+	}
+	close(release)
+	<-finished
+	if inFlight.Body.String() != "old slow" {
+		t.Errorf("Expected the request in flight to finish on the old tree, found %q", inFlight.Body.String())
+	}
+	diff, err = s.Swap(context.Background(), old)
+	if err != nil || diff.Empty() {
+		t.Errorf("Expected the swap back to drain at once, found %v", err)
+	}
+	if diff := DiffRoutes(old.Routes(), old.Routes()); !diff.Empty() {
+		t.Errorf("Expected no changes between the same routes, found %q", diff.String())
+	}
+}
@@ -15,7 +15,9 @@ type HostSwitch map[string]http.Handler
 func (hs HostSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Check if a http.Handler is registered for the given host.
 	// If yes, use it to handle the request.
-	if handler := hs[r.Host]; handler != nil {
+	// The host told by trusted proxies wins, if the request was resolved
+	// by a tree trusting them or by TrustedProxies.Handler.
+	if handler := hs[Host(r)]; handler != nil {
 		handler.ServeHTTP(w, r)
 	} else {
 		// Handle host names for wich no handler is registered
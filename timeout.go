@@ -0,0 +1,178 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutResponse is sent when a request times out before its handler
+// wrote anything.
+type TimeoutResponse struct {
+	// Defaults to 503 Service Unavailable. 504 Gateway Timeout is the
+	// other usual choice.
+	Status      int
+	Body        string
+	ContentType string
+}
+
+var defaultTimeoutResponse = &TimeoutResponse{
+	Status:      http.StatusServiceUnavailable,
+	Body:        "Service Unavailable\n",
+	ContentType: "text/plain; charset=utf-8",
+}
+
+// Sets a deadline on the context of requests dispatched to the routes
+// registered inside the closure. Expired requests get a 503 response.
+// Deeper scopes override the timeout of the outer ones, while deadlines
+// already set on the incoming request context still apply.
+// WebSocket and SSE routes are left out, as their connections are meant to
+// outlive any request deadline.
+func (m *Medeina) Timeout(d time.Duration, handle Handle) {
+	m.TimeoutWith(d, defaultTimeoutResponse, handle)
+}
+
+// As Timeout but sending the given response on expiry.
+// If the handler had already written its headers, the response can't be
+// replaced: the connection is aborted instead, so clients don't mistake
+// the truncated response for a complete one.
+func (m *Medeina) TimeoutWith(d time.Duration, response *TimeoutResponse, handle Handle) {
+	if response.Status == 0 {
+		copied := *response
+		copied.Status = http.StatusServiceUnavailable
+		response = &copied
+	}
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		if route.Timeout != 0 || route.WebSocket || route.SSE {
+			return handle
+		}
+		route.Timeout = d
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			tw := &timeoutWriter{
+				ctx:    ctx,
+				w:      w,
+				header: make(http.Header),
+			}
+			done := make(chan struct{})
+			panics := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panics <- p
+					}
+				}()
+				handle(tw, r.WithContext(ctx), ps)
+				close(done)
+			}()
+			select {
+			case p := <-panics:
+				panic(p)
+			case <-done:
+				tw.finish()
+			case <-ctx.Done():
+				if tw.timeout(response) {
+					panic(http.ErrAbortHandler)
+				}
+			}
+		}
+	}, handle)
+}
+
+// Guards the response writer so a handler still running after its
+// timeout can't write to it.
+type timeoutWriter struct {
+	mutex       sync.Mutex
+	ctx         context.Context
+	w           http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if !tw.expired() {
+		tw.writeHeader(code)
+	}
+}
+
+// Tells if the handler can't start the response anymore. Once the
+// deadline passes, it loses the race against the timeout response even if
+// this one wasn't sent yet. Must be called with the lock held.
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || (!tw.wroteHeader && tw.ctx.Err() != nil)
+}
+
+// Copies the headers set by the handler and sends them. Must be called
+// with the lock held.
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	header := tw.w.Header()
+	for key, values := range tw.header {
+		header[key] = values
+	}
+	tw.w.WriteHeader(code)
+	// Informational responses aren't final.
+	tw.wroteHeader = code >= 200
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Sends the headers of a handler which returned in time without writing
+// anything.
+func (tw *timeoutWriter) finish() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if !tw.wroteHeader && !tw.timedOut {
+		tw.writeHeader(http.StatusOK)
+	}
+}
+
+// Stops the handler from writing and sends the timeout response if
+// possible. Returns true if the headers had already been sent.
+func (tw *timeoutWriter) timeout(response *TimeoutResponse) bool {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader {
+		return true
+	}
+	if response.ContentType != "" {
+		tw.w.Header().Set("Content-Type", response.ContentType)
+	}
+	tw.w.WriteHeader(response.Status)
+	tw.w.Write([]byte(response.Body))
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	select {
+	case <-r.Context().Done():
+		fmt.Fprint(w, "too late")
+	case <-time.After(50 * time.Millisecond):
+		fmt.Fprint(w, "done")
+	}
+}
+
+func loadTimeoutMedeina() *Medeina {
+	mr := NewMedeina()
+	mr.TimeoutWith(10*time.Millisecond, &TimeoutResponse{Status: http.StatusGatewayTimeout, Body: "timeout"}, func() {
+		mr.Is("search", slowHandler, GET)
+		mr.Is("stream", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.WriteHeader(http.StatusOK)
+			<-r.Context().Done()
+			time.Sleep(time.Millisecond)
+			fmt.Fprint(w, "too late")
+		}, GET)
+		mr.On("reports", func() {
+			mr.Timeout(time.Second, func() {
+				mr.Is("", slowHandler, GET)
+			})
+		})
+	})
+	return mr
+}
+
+func TestTimeout(t *testing.T) {
+	mr := loadTimeoutMedeina()
+	r, _ := http.NewRequest("GET", "/search", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusGatewayTimeout || w.Body.String() != "timeout" {
+		t.Errorf("Expected timeout response, found Code=%d %q", w.Code, w.Body.String())
+	}
+	r, _ = http.NewRequest("GET", "/reports", nil)
+	w = httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("Expected deeper scope overriding the timeout, found Code=%d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutHeadersOnly(t *testing.T) {
+	mr := NewMedeina()
+	mr.Timeout(time.Second, func() {
+		mr.Is("empty", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			w.Header().Set("X-Total-Count", "0")
+		}, GET)
+	})
+	r, _ := http.NewRequest("GET", "/empty", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "0" {
+		t.Errorf("Expected the headers set by the handler, found Code=%d %v", w.Code, w.Header())
+	}
+}
+
+func TestTimeoutIncomingDeadline(t *testing.T) {
+	mr := loadTimeoutMedeina()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	r, _ := http.NewRequestWithContext(ctx, "GET", "/reports", nil)
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected incoming deadline honoured, found Code=%d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutAfterHeaders(t *testing.T) {
+	mr := loadTimeoutMedeina()
+	r, _ := http.NewRequest("GET", "/stream", nil)
+	w := httptest.NewRecorder()
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected aborted handler, found %v", p)
+		}
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Not expected writes after the timeout, found Code=%d %q", w.Code, w.Body.String())
+		}
+	}()
+	mr.ServeHTTP(w, r)
+}
+
+func TestTimeoutWebSocket(t *testing.T) {
+	mr := NewMedeina()
+	mr.Timeout(10*time.Millisecond, func() {
+		mr.WebSocket("events", func(conn *WebSocketConn, ps Params) {
+			time.Sleep(20 * time.Millisecond)
+			conn.WriteMessage(TextMessage, []byte("still here"))
+		})
+	})
+	server := httptest.NewServer(mr)
+	defer server.Close()
+	conn, _, err := dialTest(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/events", nil)
+	if err != nil {
+		t.Fatalf("Expected the handshake to succeed, found %v", err)
+	}
+	defer conn.Close()
+	if _, message, err := conn.ReadMessage(); err != nil || string(message) != "still here" {
+		t.Errorf("Expected the connection to outlive the timeout, found %q %v", message, err)
+	}
+	if routes := mr.Routes(); len(routes) != 1 || routes[0].Timeout != 0 {
+		t.Errorf("Not expected a timeout on WebSocket routes, found %v", routes)
+	}
+}
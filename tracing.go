@@ -0,0 +1,247 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans, in the spirit of OpenTelemetry but without depending
+// on it. Implementations must honour the parent span found in the context,
+// either a local one (SpanFromContext) or a remote one extracted from a
+// traceparent header (RemoteSpanContextFromContext).
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation.
+type Span interface {
+	SetName(name string)
+	SetAttribute(key, value string)
+	SpanContext() SpanContext
+	End()
+}
+
+// SpanContext identifies a span across processes, as in W3C Trace Context.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// Tells if both trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// Formats the span context as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceIDString(), sc.SpanIDString(), sc.Flags)
+}
+
+// Parses a traceparent header value.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	// Version 00 has exactly four fields; later ones may add more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if len(parts[1]) != 32 {
+		return sc, fmt.Errorf("invalid trace ID in traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID in traceparent %q", value)
+	}
+	if len(parts[2]) != 16 {
+		return sc, fmt.Errorf("invalid span ID in traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span ID in traceparent %q", value)
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid flags in traceparent %q", value)
+	}
+	sc.Flags = byte(flags)
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return sc, nil
+}
+
+// Header used by W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+type spanKey struct{}
+type remoteSpanKey struct{}
+
+// Returns the current span, if any.
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// Returns a copy of the context holding the span.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Returns the span context extracted from an incoming traceparent header.
+func RemoteSpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(remoteSpanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Extracts the traceparent header into the context. Invalid headers are
+// ignored, as mandated by W3C Trace Context.
+func ExtractTraceparent(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteSpanKey{}, sc)
+}
+
+// Sets the traceparent header for the current span, so outgoing requests
+// made while handling a request keep its trace.
+func InjectTraceparent(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		if sc := span.SpanContext(); sc.IsValid() {
+			header.Set(TraceparentHeader, sc.Traceparent())
+		}
+		return
+	}
+	if sc, ok := RemoteSpanContextFromContext(ctx); ok {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Sets the tracer which creates a span for every request. Spans are named
+// after the method and, once a route matches, its pattern. A nil tracer
+// disables tracing.
+func (m *Medeina) Tracer(tracer Tracer) {
+	m.tracer = tracer
+}
+
+// Starts the span of a request, before it is routed.
+func (m *Medeina) startSpan(w http.ResponseWriter, r *http.Request) (*responseWriter, *http.Request, Span) {
+	ctx := ExtractTraceparent(r.Context(), r.Header)
+	ctx, span := m.tracer.Start(ctx, r.Method)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	if id := RequestIDFromContext(ctx); id != "" {
+		span.SetAttribute("medeina.request_id", id)
+	}
+	return newResponseWriter(w), r.WithContext(ctx), span
+}
+
+// Names the span of a request after its matched route.
+func traceRoute(span Span, route *Route, ps Params) {
+	span.SetName(route.Method + " " + route.Path)
+	span.SetAttribute("http.route", route.Path)
+	for _, p := range ps {
+		span.SetAttribute("medeina.param."+p.Key, p.Value)
+	}
+}
+
+// MemoryTracer is a Tracer which records spans in memory. It is meant for
+// tests and debugging, no collector is needed.
+type MemoryTracer struct {
+	mutex sync.Mutex
+	spans []*RecordedSpan
+}
+
+// Returns a new in-memory tracer.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+func (mt *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &RecordedSpan{
+		Name:       name,
+		Attributes: make(map[string]string),
+		Start:      time.Now(),
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.Parent = parent.SpanContext()
+	} else if remote, ok := RemoteSpanContextFromContext(ctx); ok {
+		span.Parent = remote
+	}
+	span.Context.TraceID = span.Parent.TraceID
+	span.Context.Flags = span.Parent.Flags
+	if span.Context.TraceID == [16]byte{} {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Flags = 1
+	}
+	rand.Read(span.Context.SpanID[:])
+	mt.mutex.Lock()
+	mt.spans = append(mt.spans, span)
+	mt.mutex.Unlock()
+	return ContextWithSpan(ctx, span), span
+}
+
+// Returns the spans started so far, ended or not.
+func (mt *MemoryTracer) Spans() []*RecordedSpan {
+	mt.mutex.Lock()
+	defer mt.mutex.Unlock()
+	return append([]*RecordedSpan(nil), mt.spans...)
+}
+
+// RecordedSpan is a span recorded by MemoryTracer.
+type RecordedSpan struct {
+	mutex      sync.Mutex
+	Name       string
+	Attributes map[string]string
+	Context    SpanContext
+	Parent     SpanContext
+	Start      time.Time
+	Finish     time.Time
+}
+
+func (rs *RecordedSpan) SetName(name string) {
+	rs.mutex.Lock()
+	rs.Name = name
+	rs.mutex.Unlock()
+}
+
+func (rs *RecordedSpan) SetAttribute(key, value string) {
+	rs.mutex.Lock()
+	rs.Attributes[key] = value
+	rs.mutex.Unlock()
+}
+
+func (rs *RecordedSpan) SpanContext() SpanContext {
+	return rs.Context
+}
+
+func (rs *RecordedSpan) End() {
+	rs.mutex.Lock()
+	rs.Finish = time.Now()
+	rs.mutex.Unlock()
+}
+
+// Tells if the span was ended.
+func (rs *RecordedSpan) Ended() bool {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return !rs.Finish.IsZero()
+}
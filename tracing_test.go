@@ -0,0 +1,81 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Traceparent() != value {
+		t.Errorf("Expected %s, found %s", value, sc.Traceparent())
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473601-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b701-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	tracer := NewMemoryTracer()
+	mr := NewMedeina()
+	mr.Tracer(tracer)
+	var outgoing http.Header
+	mr.On("repos/:owner/:repo", func() {
+		mr.Is("pulls/:number", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			outgoing = make(http.Header)
+			InjectTraceparent(r.Context(), outgoing)
+			w.WriteHeader(http.StatusAccepted)
+		}, GET)
+	})
+	r, _ := http.NewRequest("GET", "/repos/imdario/medeina/pulls/42", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mr.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest("GET", "/deadmau5", nil)
+	mr.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := tracer.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, found %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /repos/:owner/:repo/pulls/:number" || !span.Ended() {
+		t.Errorf("Unexpected span %s", span.Name)
+	}
+	for key, value := range map[string]string{
+		"http.route":                "/repos/:owner/:repo/pulls/:number",
+		"medeina.param.owner":       "imdario",
+		"medeina.param.number":      "42",
+		"http.response.status_code": "202",
+	} {
+		if span.Attributes[key] != value {
+			t.Errorf("Expected attribute %s=%s, found %q", key, value, span.Attributes[key])
+		}
+	}
+	if span.Context.TraceIDString() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.SpanIDString() != "00f067aa0ba902b7" {
+		t.Errorf("Expected span continuing the incoming trace, found %s", span.Context.Traceparent())
+	}
+	if outgoing.Get(TraceparentHeader) != span.Context.Traceparent() {
+		t.Errorf("Expected outgoing traceparent %s, found %s", span.Context.Traceparent(), outgoing.Get(TraceparentHeader))
+	}
+	if unmatched := spans[1]; unmatched.Name != "GET" || unmatched.Attributes["http.response.status_code"] != "404" || unmatched.Parent.IsValid() {
+		t.Errorf("Unexpected unmatched span %s %v", unmatched.Name, unmatched.Attributes)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Validation sets the requirements requests must meet to reach the routes
+// of a scope.
+type Validation struct {
+	// Maximum request body size in bytes. Zero means no limit.
+	MaxBodySize int64
+	// Media types allowed for request bodies, e.g. application/json or
+	// multipart/*. Empty allows any.
+	ContentTypes []string
+	// Headers every request must carry.
+	RequiredHeaders []string
+}
+
+// Checks a request, returning the status and message of the first
+// violation found, or zero if the request is valid.
+func (v *Validation) check(r *http.Request) (int, string) {
+	for _, header := range v.RequiredHeaders {
+		if r.Header.Get(header) == "" {
+			return http.StatusBadRequest, fmt.Sprintf("missing header %s", header)
+		}
+	}
+	if v.MaxBodySize > 0 && r.ContentLength > v.MaxBodySize {
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("body larger than %d bytes", v.MaxBodySize)
+	}
+	// Only requests with a body need a content type.
+	if len(v.ContentTypes) > 0 && r.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !v.allowContentType(mediaType) {
+			return http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type"))
+		}
+	}
+	return 0, ""
+}
+
+func (v *Validation) allowContentType(mediaType string) bool {
+	for _, allowed := range v.ContentTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates every request dispatched to the routes registered inside the
+// closure before their handlers run. Missing headers get a 400 response,
+// bodies over the limit a 413 and unexpected content types a 415.
+// Bodies without Content-Length are cut at the limit, so handlers get a
+// *http.MaxBytesError when reading past it.
+// Deeper scopes override the validations of the outer ones.
+func (m *Medeina) Validate(validation *Validation, handle Handle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		if route.Validation != nil {
+			return handle
+		}
+		route.Validation = validation
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			if status, message := validation.check(r); status != 0 {
+				http.Error(w, message, status)
+				return
+			}
+			if validation.MaxBodySize > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, validation.MaxBodySize)
+			}
+			handle(w, r, ps)
+		}
+	}, handle)
+}
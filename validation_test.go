@@ -0,0 +1,84 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loadValidationMedeina() *Medeina {
+	read := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+	uploads := http.NewServeMux()
+	uploads.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		read(w, r, nil)
+	})
+	mr := NewMedeina()
+	mr.Validate(&Validation{
+		MaxBodySize:     16,
+		ContentTypes:    []string{"application/json"},
+		RequiredHeaders: []string{"User-Agent"},
+	}, func() {
+		mr.On("gists", func() {
+			mr.Is("", read, GET, POST)
+		})
+		mr.Validate(&Validation{MaxBodySize: 64, ContentTypes: []string{"multipart/*"}}, func() {
+			mr.OnMux("uploads", uploads)
+		})
+	})
+	return mr
+}
+
+func TestValidation(t *testing.T) {
+	mr := loadValidationMedeina()
+	small := `{"id": 1}`
+	large := `{"description": "Medeina is a goddess"}`
+	for _, test := range []struct {
+		method      string
+		path        string
+		contentType string
+		userAgent   string
+		body        string
+		chunked     bool
+		status      int
+	}{
+		{"GET", "/gists", "", "medeina", "", false, http.StatusOK},
+		{"GET", "/gists", "", "", "", false, http.StatusBadRequest},
+		{"POST", "/gists", "application/json; charset=utf-8", "medeina", small, false, http.StatusOK},
+		{"POST", "/gists", "text/plain", "medeina", small, false, http.StatusUnsupportedMediaType},
+		{"POST", "/gists", "application/json", "medeina", large, false, http.StatusRequestEntityTooLarge},
+		{"POST", "/gists", "application/json", "medeina", large, true, http.StatusRequestEntityTooLarge},
+		{"POST", "/uploads/avatar", "multipart/form-data; boundary=x", "", large, false, http.StatusOK},
+		{"POST", "/uploads/avatar", "application/json", "", large, false, http.StatusUnsupportedMediaType},
+	} {
+		var body io.Reader = strings.NewReader(test.body)
+		if test.chunked {
+			// Hides the length, as with chunked transfer encoding.
+			body = io.MultiReader(body)
+		}
+		r, _ := http.NewRequest(test.method, test.path, body)
+		if test.chunked {
+			r.ContentLength = -1
+		}
+		r.Header.Set("Content-Type", test.contentType)
+		r.Header.Set("User-Agent", test.userAgent)
+		w := httptest.NewRecorder()
+		mr.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("Expected %d for %s %s (%s), found %d", test.status, test.method, test.path, test.contentType, w.Code)
+		}
+	}
+}
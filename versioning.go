@@ -0,0 +1,228 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Versioning configures a versioned tree.
+type Versioning struct {
+	// Versions served, oldest first, e.g. v1 and v2. They're used as the
+	// first segment of the path of each version.
+	Versions []string
+	// Version of unversioned requests. Defaults to the latest one.
+	Default string
+	// Vendor in Accept media types selecting a version, as x in
+	// application/vnd.x.v2+json.
+	Vendor string
+	// Query param selecting a version, e.g. version.
+	Query string
+	// Deprecated versions. Deprecations of routes inside them win.
+	Deprecated map[string]*Deprecation
+
+	prefix  string
+	current int
+	since   int
+	pending []*versionedRoute
+}
+
+// A route waiting for the whole version to be defined, as it could be
+// overridden later.
+type versionedRoute struct {
+	route  *Route
+	handle httprouter.Handle
+	since  int
+}
+
+// Returns the position of a version, panicking if it's unknown.
+func (v *Versioning) index(version string) int {
+	for i, known := range v.Versions {
+		if known == version {
+			return i
+		}
+	}
+	panic(fmt.Errorf("unknown version %q", version))
+}
+
+func (v *Versioning) known(version string) bool {
+	for _, known := range v.Versions {
+		if known == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Defines a versioned tree under the current path. The closure defines the
+// base tree and is run once per version, under a subpath named after it.
+// Inside it, Since and Until override and remove routes in some versions.
+// Requests without version in their path get the one in their Accept
+// header or query, or the default one. Deprecated versions answer with
+// Deprecation and Sunset headers.
+//
+//	m.Versions(&Versioning{Versions: []string{"v1", "v2"}}, func() {
+//		m.Is("repos/:owner", listRepos, GET)
+//		m.Since("v2", func() {
+//			m.Is("repos/:owner", listReposPaginated, GET)
+//		})
+//		m.Until("v1", func() {
+//			m.Is("legacy", legacy, GET)
+//		})
+//	})
+func (m *Medeina) Versions(versioning *Versioning, handle Handle) {
+	if m.versioning != nil {
+		panic(fmt.Errorf("you cannot nest versioned trees"))
+	}
+	if len(versioning.Versions) == 0 {
+		panic(fmt.Errorf("you must set at least a version"))
+	}
+	if versioning.Default == "" {
+		versioning.Default = versioning.Versions[len(versioning.Versions)-1]
+	}
+	versioning.index(versioning.Default)
+	versioning.prefix = joinDeque(m.path)
+	m.versioning = versioning
+	for i, version := range versioning.Versions {
+		versioning.current, versioning.since = i, -1
+		m.On(version, func() {
+			m.Use(versioning.middleware(version), handle)
+		})
+		// Routes from the most specific Since scope win.
+		winners := make(map[string]*versionedRoute)
+		for _, pending := range versioning.pending {
+			key := pending.route.Method + " " + pending.route.Path
+			if winner, ok := winners[key]; !ok || pending.since > winner.since {
+				winners[key] = pending
+			}
+		}
+		for _, pending := range versioning.pending {
+			// Routes defined twice in the same scope still conflict.
+			if pending.since < winners[pending.route.Method+" "+pending.route.Path].since {
+				continue
+			}
+			m.add(pending.route, pending.handle)
+		}
+		versioning.pending = nil
+	}
+	m.versioning = nil
+	m.versionings = append(m.versionings, versioning)
+}
+
+// Tags routes with their version, deprecating them if needed.
+func (v *Versioning) middleware(version string) Middleware {
+	deprecation := v.Deprecated[version]
+	return func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.Version = version
+		if deprecation == nil {
+			return handle
+		}
+		return deprecation.middleware(route, handle)
+	}
+}
+
+// Defines routes only present since the given version, overriding the
+// ones with the same method and path defined outside.
+func (m *Medeina) Since(version string, handle Handle) {
+	v := m.versioning
+	if v == nil {
+		panic(fmt.Errorf("you cannot use Since outside a versioned tree"))
+	}
+	since := v.index(version)
+	if v.current < since {
+		return
+	}
+	previous := v.since
+	if since > previous {
+		v.since = since
+	}
+	handle()
+	v.since = previous
+}
+
+// Defines routes only present until the given version, included.
+func (m *Medeina) Until(version string, handle Handle) {
+	v := m.versioning
+	if v == nil {
+		panic(fmt.Errorf("you cannot use Until outside a versioned tree"))
+	}
+	if v.current <= v.index(version) {
+		handle()
+	}
+}
+
+// Returns the version being defined, or an empty string outside a
+// versioned tree.
+func (m *Medeina) Version() string {
+	if m.versioning == nil {
+		return ""
+	}
+	return m.versioning.Versions[m.versioning.current]
+}
+
+// Finds the version asked for in the Accept header, if any.
+func (v *Versioning) fromAccept(accept string) (string, bool) {
+	if v.Vendor == "" {
+		return "", false
+	}
+	prefix := "application/vnd." + v.Vendor + "."
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !strings.HasPrefix(mediaType, prefix) {
+			continue
+		}
+		version, _, _ := strings.Cut(mediaType[len(prefix):], "+")
+		return version, true
+	}
+	return "", false
+}
+
+// Rewrites unversioned requests to the path of the selected version. It
+// returns false if the request asked for an unknown version, which is
+// answered here.
+func (m *Medeina) selectVersion(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	for _, v := range m.versionings {
+		rest := r.URL.Path
+		if v.prefix != "" {
+			if rest = strings.TrimPrefix(r.URL.Path, v.prefix); rest == r.URL.Path || (rest != "" && rest[0] != '/') {
+				continue
+			}
+		}
+		segment, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+		if v.known(segment) {
+			return r, true
+		}
+		// Routes outside the versioned tree are left alone.
+		if handle, _, _ := m.router.Lookup(r.Method, r.URL.Path); handle != nil {
+			return r, true
+		}
+		version := v.Default
+		if v.Vendor != "" {
+			w.Header().Add("Vary", "Accept")
+		}
+		if requested, ok := v.fromAccept(r.Header.Get("Accept")); ok {
+			if !v.known(requested) {
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return r, false
+			}
+			version = requested
+		} else if requested := r.URL.Query().Get(v.Query); v.Query != "" && requested != "" {
+			if !v.known(requested) {
+				http.Error(w, fmt.Sprintf("unknown version %q", requested), http.StatusBadRequest)
+				return r, false
+			}
+			version = requested
+		}
+		rewritten := new(http.Request)
+		*rewritten = *r
+		u := *r.URL
+		u.Path = v.prefix + "/" + version + rest
+		u.RawPath = ""
+		rewritten.URL = &u
+		return rewritten, true
+	}
+	return r, true
+}
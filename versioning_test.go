@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func loadVersionedMedeina() *Medeina {
+	text := func(body string) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			fmt.Fprint(w, body+" "+httprouter.Params(ps).ByName("owner"))
+		}
+	}
+	mr := NewMedeina()
+	mr.Is("health", text("ok"), GET)
+	mr.On("api", func() {
+		mr.Versions(&Versioning{
+			Versions: []string{"v1", "v2", "v3"},
+			Default:  "v2",
+			Vendor:   "medeina",
+			Query:    "version",
+			Deprecated: map[string]*Deprecation{
+				"v1": {
+					Date:   time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC),
+					Sunset: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		}, func() {
+			mr.GET(func() {
+				mr.Is("users/:owner", text("user "+mr.Version()))
+				mr.Is("repos/:owner", text("repos"))
+				mr.Since("v2", func() {
+					mr.Is("repos/:owner", text("paginated repos"))
+				})
+				mr.Since("v3", func() {
+					mr.Is("repos/:owner", text("cursor repos"))
+				})
+				mr.Until("v1", func() {
+					mr.Is("legacy", text("legacy"))
+				})
+			})
+		})
+	})
+	return mr
+}
+
+func versionRequest(mr *Medeina, path, accept string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", path, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	mr.ServeHTTP(w, r)
+	return w
+}
+
+func TestVersions(t *testing.T) {
+	mr := loadVersionedMedeina()
+	tests := []struct {
+		path, accept, body string
+		status             int
+	}{
+		{"/api/v1/repos/imdario", "", "repos imdario", http.StatusOK},
+		{"/api/v2/repos/imdario", "", "paginated repos imdario", http.StatusOK},
+		{"/api/v3/repos/imdario", "", "cursor repos imdario", http.StatusOK},
+		{"/api/v3/users/imdario", "", "user v3 imdario", http.StatusOK},
+		{"/api/v1/legacy", "", "legacy ", http.StatusOK},
+		{"/api/v2/legacy", "", "", http.StatusNotFound},
+		{"/api/repos/imdario", "", "paginated repos imdario", http.StatusOK},
+		{"/api/repos/imdario", "application/vnd.medeina.v3+json", "cursor repos imdario", http.StatusOK},
+		{"/api/repos/imdario?version=v1", "", "repos imdario", http.StatusOK},
+		{"/api/repos/imdario", "application/vnd.medeina.v9+json", "", http.StatusNotAcceptable},
+		{"/api/repos/imdario?version=v9", "", "", http.StatusBadRequest},
+		{"/health", "application/vnd.medeina.v3+json", "ok ", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := versionRequest(mr, test.path, test.accept)
+		if w.Code != test.status || (test.body != "" && w.Body.String() != test.body) {
+			t.Errorf("Expected %s (%s) to answer %d %q, found %d %q", test.path, test.accept, test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestVersionsDeprecation(t *testing.T) {
+	mr := loadVersionedMedeina()
+	w := versionRequest(mr, "/api/users/imdario", "application/vnd.medeina.v1+json")
+	if w.Header().Get("Deprecation") != "@1401580800" || w.Header().Get("Sunset") != "Thu, 01 Jan 2015 00:00:00 GMT" {
+		t.Errorf("Expected deprecation headers for v1, found %q and %q", w.Header().Get("Deprecation"), w.Header().Get("Sunset"))
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected Vary: Accept for unversioned requests, found %q", w.Header().Get("Vary"))
+	}
+	w = versionRequest(mr, "/api/v2/users/imdario", "")
+	if w.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected no deprecation headers for v2, found %q", w.Header().Get("Deprecation"))
+	}
+	route, _, ok := mr.Match("GET", "/api/v3/repos/imdario")
+	if !ok || route.Version != "v3" {
+		t.Errorf("Expected the route to be tagged with its version, found %v", route)
+	}
+	var count int
+	for _, route := range mr.Routes() {
+		if route.Path == "/api/v2/repos/:owner" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected overridden routes to be registered once, found %d", count)
+	}
+}
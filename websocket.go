@@ -0,0 +1,581 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Kinds of WebSocket data messages.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// WebSocket frame opcodes, as in RFC 6455 section 5.2.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// WebSocket close codes, as in RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// Appended to the client key to compute Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWebSocketClosed is returned when writing to a closed connection.
+var ErrWebSocketClosed = errors.New("medeina: websocket closed")
+
+// WebSocketCloseError is returned by ReadMessage when the connection is
+// closed, by the peer or because it broke the protocol.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("medeina: websocket closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("medeina: websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WebSocketOptions configures the handshake of WebSocket routes.
+type WebSocketOptions struct {
+	// Origins allowed to connect, with the same syntax as in CORSPolicy.
+	// When empty, browsers can only connect from the same host. Requests
+	// without Origin, which don't come from browsers, are always allowed.
+	AllowedOrigins []string
+	// Subprotocols supported by the server, in order of preference.
+	Subprotocols []string
+	// Largest message accepted. Defaults to 1 MB.
+	MaxMessageSize int64
+}
+
+// WebSocketHandle serves an upgraded connection. The connection is closed
+// once it returns.
+type WebSocketHandle func(conn *WebSocketConn, ps Params)
+
+// Registers a WebSocket endpoint for GET requests in the current context.
+// It shares the scopes and middlewares of any other route, and only
+// same-host browser origins are allowed.
+func (m *Medeina) WebSocket(path string, handle WebSocketHandle) {
+	m.WebSocketWith(path, &WebSocketOptions{}, handle)
+}
+
+// As WebSocket but with the given options.
+func (m *Medeina) WebSocketWith(path string, opts *WebSocketOptions, handle WebSocketHandle) {
+	m.Use(func(route *Route, handle httprouter.Handle) httprouter.Handle {
+		route.WebSocket = true
+		return handle
+	}, func() {
+		m.Is(path, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			conn, err := upgradeWebSocket(w, r, opts)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			handle(conn, Params(ps))
+		}, GET)
+	})
+}
+
+// Tells if a comma separated header has the given token.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Computes the Sec-WebSocket-Accept value for a client key.
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Tells if a browser origin may connect.
+func (opts *WebSocketOptions) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(opts.AllowedOrigins) > 0 {
+		return (&CORSPolicy{AllowedOrigins: opts.AllowedOrigins}).allowOrigin(origin)
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, Host(r))
+}
+
+// Checks the opening handshake and switches protocols, answering with an
+// error status if the request isn't a valid upgrade.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, opts *WebSocketOptions) (*WebSocketConn, error) {
+	fail := func(status int, reason string) (*WebSocketConn, error) {
+		http.Error(w, http.StatusText(status), status)
+		return nil, fmt.Errorf("medeina: websocket handshake failed: %s", reason)
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		return fail(http.StatusUpgradeRequired, "not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid key")
+	}
+	if !opts.allowOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+	var subprotocol string
+	for _, supported := range opts.Subprotocols {
+		if headerHasToken(r.Header, "Sec-WebSocket-Protocol", supported) {
+			subprotocol = supported
+			break
+		}
+	}
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	// Deadlines set by the server don't apply to the upgraded connection.
+	netConn.SetDeadline(time.Time{})
+	header := w.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", websocketAccept(key))
+	if subprotocol != "" {
+		header.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	for _, name := range []string{"Content-Type", "Content-Length", "Transfer-Encoding", "Vary"} {
+		header.Del(name)
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	markSwitched(w)
+	return newWebSocketConn(netConn, brw.Reader, false, r, subprotocol, opts.MaxMessageSize), nil
+}
+
+// Records the switch of protocols in the writer observed by dispatch, as
+// net/http doesn't see the hijacked response.
+func markSwitched(w http.ResponseWriter) {
+	for w != nil {
+		if rw, ok := w.(*responseWriter); ok {
+			rw.status = http.StatusSwitchingProtocols
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// WebSocketConn is a WebSocket connection, either accepted by a WebSocket
+// route or opened with DialWebSocket. Messages can be written from several
+// goroutines, but only one may read.
+type WebSocketConn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	client         bool
+	request        *http.Request
+	subprotocol    string
+	maxMessageSize int64
+
+	readMutex  sync.Mutex
+	writeMutex sync.Mutex
+	closeSent  bool
+	closeOnce  sync.Once
+}
+
+func newWebSocketConn(conn net.Conn, reader *bufio.Reader, client bool, r *http.Request, subprotocol string, maxMessageSize int64) *WebSocketConn {
+	if maxMessageSize <= 0 {
+		maxMessageSize = 1 << 20
+	}
+	return &WebSocketConn{
+		conn:           conn,
+		reader:         reader,
+		client:         client,
+		request:        r,
+		subprotocol:    subprotocol,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Returns the request which opened the connection.
+func (c *WebSocketConn) Request() *http.Request {
+	return c.request
+}
+
+// Returns the negotiated subprotocol, if any.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Returns the underlying connection, e.g. to set deadlines.
+func (c *WebSocketConn) NetConn() net.Conn {
+	return c.conn
+}
+
+// Reads the next data message. Pings are answered and pongs skipped
+// meanwhile. When the peer closes the connection, or breaks the protocol,
+// it returns a *WebSocketCloseError.
+func (c *WebSocketConn) ReadMessage() (MessageType, []byte, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	var (
+		kind    MessageType
+		message []byte
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.closed(payload)
+		case opContinuation:
+			if kind == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		case opText, opBinary:
+			if kind != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unfinished fragmented message")
+			}
+			kind = MessageType(opcode)
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			if kind == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8")
+			}
+			return kind, message, nil
+		}
+	}
+}
+
+// Reads a single frame, unmasking its payload.
+func (c *WebSocketConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return false, 0, nil, c.broken(err)
+	}
+	fin, opcode := head[0]&0x80 != 0, head[0]&0x0f
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	// Clients must mask their frames, servers mustn't.
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return false, 0, nil, c.fail(CloseProtocolError, "wrong masking")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, c.broken(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, c.broken(err)
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= opClose && (!fin || length > 125) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > uint64(c.maxMessageSize) {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, key[:]); err != nil {
+			return false, 0, nil, c.broken(err)
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, c.broken(err)
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+// Answers a close frame from the peer and closes the connection.
+func (c *WebSocketConn) closed(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseProtocolError, "invalid close reason")
+		}
+	}
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	c.close(code, "", false)
+	return closeErr
+}
+
+// Closes the connection because the peer broke the protocol.
+func (c *WebSocketConn) fail(code int, reason string) error {
+	c.close(code, reason, true)
+	return &WebSocketCloseError{Code: code, Reason: reason}
+}
+
+// Closes a connection which can't be read anymore.
+func (c *WebSocketConn) broken(err error) error {
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+	})
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return &WebSocketCloseError{Code: 1006, Reason: "connection lost"}
+	}
+	return err
+}
+
+// Sends a data message.
+func (c *WebSocketConn) WriteMessage(kind MessageType, data []byte) error {
+	if kind != TextMessage && kind != BinaryMessage {
+		return fmt.Errorf("medeina: invalid websocket message type %d", kind)
+	}
+	return c.writeFrame(byte(kind), data)
+}
+
+// Sends a ping. The pong answered by the peer is skipped by ReadMessage.
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return fmt.Errorf("medeina: websocket ping payload too long")
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// Writes a single, final frame, masking it when sent by a client.
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Closes the connection normally.
+func (c *WebSocketConn) Close() error {
+	return c.CloseWith(CloseNormal, "")
+}
+
+// Sends a close frame with the given code and reason, unless one was
+// already sent, and closes the connection.
+func (c *WebSocketConn) CloseWith(code int, reason string) error {
+	// A concurrent ReadMessage gets the answer of the peer instead.
+	if c.readMutex.TryLock() {
+		defer c.readMutex.Unlock()
+		return c.close(code, reason, true)
+	}
+	return c.close(code, reason, false)
+}
+
+// Sends a close frame and closes the connection. When the close is
+// initiated here, it waits a bit for the peer to answer, discarding what
+// it sent meanwhile: closing a socket with unread data resets it, and the
+// peer could lose the close frame.
+func (c *WebSocketConn) close(code int, reason string, wait bool) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	} else if err == nil && wait {
+		c.conn.SetReadDeadline(time.Now().Add(time.Second))
+		io.Copy(io.Discard, c.reader)
+	}
+	c.closeOnce.Do(func() {
+		if closeErr := c.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// Opens a WebSocket connection to the given ws, wss, http or https URL.
+// It is meant for tests and service to service calls; the handshake
+// response is returned even if it fails, when there's one.
+func DialWebSocket(ctx context.Context, rawURL string, header http.Header) (*WebSocketConn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, secure = "https", true
+	default:
+		return nil, nil, fmt.Errorf("medeina: unsupported websocket scheme %q", u.Scheme)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		if secure {
+			address = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			address = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	var conn net.Conn
+	if secure {
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	r, err := http.NewRequestWithContext(ctx, GET, u.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", key)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := r.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, r)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, resp, fmt.Errorf("medeina: websocket handshake failed with status %d", resp.StatusCode)
+	}
+	conn.SetDeadline(time.Time{})
+	return newWebSocketConn(conn, reader, true, r, resp.Header.Get("Sec-WebSocket-Protocol"), 0), resp, nil
+}
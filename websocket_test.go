@@ -0,0 +1,131 @@
+// Copyright (c) 2014 Dario Castañé. Licensed under the MIT License.
+package medeina
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadWebSocketMedeina() *Medeina {
+	mr := NewMedeina()
+	auth := BearerAuth("api", func(token string) (*Principal, error) {
+		if token != "secret" {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{Name: "octocat"}, nil
+	})
+	mr.On("repos", func() {
+		mr.Authenticate(auth, func() {
+			mr.On(":owner/:repo/events", func() {
+				mr.WebSocketWith("stream", &WebSocketOptions{Subprotocols: []string{"events.v2", "events.v1"}, MaxMessageSize: 1 << 17}, func(conn *WebSocketConn, ps Params) {
+					principal := PrincipalFromContext(conn.Request().Context())
+					conn.WriteMessage(TextMessage, []byte(principal.Name+" watching "+ps.ByName("owner")+"/"+ps.ByName("repo")))
+					for {
+						kind, message, err := conn.ReadMessage()
+						if err != nil {
+							return
+						}
+						conn.WriteMessage(kind, message)
+					}
+				})
+			})
+		})
+	})
+	return mr
+}
+
+func dialTest(t *testing.T, url string, header http.Header) (*WebSocketConn, *http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return DialWebSocket(ctx, url, header)
+}
+
+func TestWebSocket(t *testing.T) {
+	server := httptest.NewServer(loadWebSocketMedeina())
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/repos/julienschmidt/httprouter/events/stream"
+	header := http.Header{
+		"Authorization":          {"Bearer secret"},
+		"Sec-Websocket-Protocol": {"events.v1, events.v2"},
+	}
+	conn, resp, err := dialTest(t, url, header)
+	if err != nil {
+		t.Fatalf("Expected the handshake to succeed, found %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "events.v2" {
+		t.Errorf("Expected the preferred subprotocol events.v2, found %q", conn.Subprotocol())
+	}
+	if resp.Header.Get("Upgrade") != "websocket" {
+		t.Errorf("Expected Upgrade: websocket, found %q", resp.Header.Get("Upgrade"))
+	}
+	kind, message, err := conn.ReadMessage()
+	if err != nil || kind != TextMessage || string(message) != "octocat watching julienschmidt/httprouter" {
+		t.Errorf("Expected the greeting, found %v %q %v", kind, message, err)
+	}
+	large := []byte(strings.Repeat("medeina", 10000))
+	if err := conn.WriteMessage(BinaryMessage, large); err != nil {
+		t.Fatalf("Expected to write a large message, found %v", err)
+	}
+	kind, message, err = conn.ReadMessage()
+	if err != nil || kind != BinaryMessage || string(message) != string(large) {
+		t.Errorf("Expected the large message echoed, found %v %d bytes %v", kind, len(message), err)
+	}
+	if err := conn.Ping([]byte("ping")); err != nil {
+		t.Errorf("Expected to send a ping, found %v", err)
+	}
+	conn.WriteMessage(TextMessage, []byte("after ping"))
+	if _, message, err = conn.ReadMessage(); err != nil || string(message) != "after ping" {
+		t.Errorf("Expected pongs to be skipped, found %q %v", message, err)
+	}
+	conn.WriteMessage(BinaryMessage, make([]byte, 1<<18))
+	_, _, err = conn.ReadMessage()
+	var closeErr *WebSocketCloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("Expected the server to close with %d, found %v", CloseMessageTooBig, err)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := httptest.NewServer(loadWebSocketMedeina())
+	defer server.Close()
+	url := server.URL + "/repos/julienschmidt/httprouter/events/stream"
+	_, resp, err := dialTest(t, url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the authentication scope to apply, found %v", err)
+	}
+	_, resp, err = dialTest(t, url, http.Header{"Authorization": {"Bearer secret"}, "Origin": {"https://evil.example.com"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected cross-origin connections to be forbidden, found %v", err)
+	}
+	conn, _, err := dialTest(t, url, http.Header{"Authorization": {"Bearer secret"}, "Origin": {server.URL}})
+	if err != nil {
+		t.Errorf("Expected same-origin connections to be allowed, found %v", err)
+	} else {
+		conn.Close()
+	}
+	r, _ := http.NewRequest("GET", url, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	plain, err := http.DefaultClient.Do(r)
+	if err != nil || plain.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Expected 426 for plain requests, found %v", err)
+	}
+	for _, route := range loadWebSocketMedeina().Routes() {
+		flags := strings.Join(route.Flags(), ",")
+		if route.Path != "/repos/:owner/:repo/events/stream" || route.Method != "GET" || flags != "protected,websocket" {
+			t.Errorf("Expected a protected WebSocket route, found %s %s [%s]", route.Method, route.Path, flags)
+		}
+	}
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	if accept := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected s3pPLMBiTxaQ9kYGzzhZRbK+xOo=, found %s", accept)
+	}
+}